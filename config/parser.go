@@ -8,6 +8,7 @@ import (
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"os"
+	"path"
 )
 
 type Config struct {
@@ -16,22 +17,119 @@ type Config struct {
 	TokenPath             string             `yaml:"tokenPath"`
 	Namespace             string             `yaml:"namespace"`
 	Role                  string             `yaml:"role"`
+	AuthMethod            string             `yaml:"authMethod"`
 	VaultAuthMethodPath   string             `yaml:"vaultAuthMethodPath"`
+	RoleIdPath            string             `yaml:"roleIdPath"`
+	SecretIdPath          string             `yaml:"secretIdPath"`
+	JwtAudience           string             `yaml:"jwtAudience"`
 	RevokeAuthLeaseOnQuit bool               `yaml:"revokeAuthLeaseOnQuit"`
+	NotifyDebounce        *int               `yaml:"notifyDebounce"`
 	Secrets               []SecretDefinition `yaml:"secrets"`
 }
 
 type SecretDefinition struct {
-	Name          string            `yaml:"name"`
-	Origin        string            `yaml:"origin"`
-	Source        string            `yaml:"source"`
-	Destination   string            `yaml:"destination"`
-	Format        string            `yaml:"format"`
-	FileMode      os.FileMode       `yaml:"fileMode"`
-	DirectoryMode os.FileMode       `yaml:"directoryMode"`
-	SecretBaseKey string            `yaml:"secretBaseKey"`
-	Mapping       map[string]string `yaml:"mapping"`
-	Decoders      []string          `yaml:"decoders"`
+	Name               string                 `yaml:"name"`
+	Origin             string                 `yaml:"origin"`
+	Source             string                 `yaml:"source"`
+	Destination        string                 `yaml:"destination"`
+	Format             string                 `yaml:"format"`
+	FileMode           os.FileMode            `yaml:"fileMode"`
+	DirectoryMode      os.FileMode            `yaml:"directoryMode"`
+	SecretBaseKey      string                 `yaml:"secretBaseKey"`
+	Mapping            map[string]string      `yaml:"mapping"`
+	Decoders           []string               `yaml:"decoders"`
+	Notify             *NotifyDefinition      `yaml:"notify"`
+	RefreshInterval    int                    `yaml:"refreshInterval"`
+	Version            int                    `yaml:"version"`
+	List               *ListDefinition        `yaml:"list"`
+	Pki                *PkiDefinition         `yaml:"pki"`
+	Parameters         map[string]interface{} `yaml:"parameters"`
+	Transit            *TransitDefinition     `yaml:"transit"`
+	Ssh                *SshDefinition         `yaml:"ssh"`
+	Env                *EnvDefinition         `yaml:"env"`
+	Values             map[string]string      `yaml:"values"`
+	Directory          *DirectoryDefinition   `yaml:"directory"`
+	SourceFormat       string                 `yaml:"sourceFormat"`
+	Random             *RandomDefinition      `yaml:"random"`
+	Template           string                 `yaml:"template"`
+	TemplateFile       string                 `yaml:"templateFile"`
+	NestUnderName      bool                   `yaml:"nestUnderName"`
+	TruncateOnPopulate bool                   `yaml:"truncateOnPopulate"`
+	DotenvDialect      string                 `yaml:"dotenvDialect"`
+	ShellAllExport     bool                   `yaml:"shellAllExport"`
+	Keystore           *KeystoreDefinition    `yaml:"keystore"`
+}
+
+type KeystoreDefinition struct {
+	Type           string `yaml:"type"`
+	CertificateKey string `yaml:"certificateKey"`
+	PrivateKeyKey  string `yaml:"privateKeyKey"`
+	CaKey          string `yaml:"caKey"`
+	PasswordKey    string `yaml:"passwordKey"`
+	PasswordFile   string `yaml:"passwordFile"`
+	Alias          string `yaml:"alias"`
+}
+
+type RandomDefinition struct {
+	Keys    []string `yaml:"keys"`
+	Length  int      `yaml:"length"`
+	Charset string   `yaml:"charset"`
+	Bytes   int      `yaml:"bytes"`
+}
+
+type DirectoryDefinition struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+type EnvDefinition struct {
+	Variables   []string `yaml:"variables"`
+	Prefix      string   `yaml:"prefix"`
+	StripPrefix bool     `yaml:"stripPrefix"`
+}
+
+type SshDefinition struct {
+	PublicKeyFile string   `yaml:"publicKeyFile"`
+	Principals    []string `yaml:"principals"`
+	Ttl           string   `yaml:"ttl"`
+	CertType      string   `yaml:"certType"`
+}
+
+type TransitDefinition struct {
+	CiphertextFile string            `yaml:"ciphertextFile"`
+	Ciphertexts    map[string]string `yaml:"ciphertexts"`
+}
+
+type PkiDefinition struct {
+	CommonName string   `yaml:"commonName"`
+	AltNames   []string `yaml:"altNames"`
+	IpSans     []string `yaml:"ipSans"`
+	Ttl        string   `yaml:"ttl"`
+}
+
+type ListDefinition struct {
+	Recursive bool   `yaml:"recursive"`
+	KeyNaming string `yaml:"keyNaming"`
+	Separator string `yaml:"separator"`
+	Collision string `yaml:"collision"`
+}
+
+type NotifyDefinition struct {
+	Signal      string   `yaml:"signal"`
+	ProcessName string   `yaml:"processName"`
+	PidFile     string   `yaml:"pidFile"`
+	Command     []string `yaml:"command"`
+	HttpUrl     string   `yaml:"httpUrl"`
+}
+
+func (c *Config) GetSecretDefinition(name string) (SecretDefinition, bool) {
+	for _, definition := range c.Secrets {
+		if definition.Name == name {
+			return definition, true
+		}
+	}
+
+	return SecretDefinition{}, false
 }
 
 func LoadConfig(configPath string) Config {
@@ -77,20 +175,36 @@ func validateConfig(config Config) {
 		errors = append(errors, "No Vault URL set")
 	}
 
-	if "" == config.Role {
-		errors = append(errors, "No role set")
-	}
-
 	if "" == config.VaultAuthMethodPath {
 		errors = append(errors, "No Vault auth method path set")
 	}
 
+	if *config.NotifyDebounce < 0 {
+		errors = append(errors, fmt.Sprintf("Invalid notify debounce: %d", *config.NotifyDebounce))
+	}
+
+	validateAuthMethod(config, &errors)
+
+	secretNames := []string{}
+	destinationFormats := map[string]string{}
+
 	for i := range config.Secrets {
 		validateSecret(&config.Secrets[i], i, &errors)
-	}
 
-	if !helper.FileExists(config.TokenPath) {
-		errors = append(errors, "Token file does not exist at "+config.TokenPath)
+		if helper.StringInSlice(secretNames, config.Secrets[i].Name) {
+			errors = append(errors, fmt.Sprintf("Duplicate name for secret #%d: %s", i, config.Secrets[i].Name))
+		}
+
+		secretNames = append(secretNames, config.Secrets[i].Name)
+
+		destination := path.Clean(config.Secrets[i].Destination)
+
+		if format, ok := destinationFormats[destination]; ok && format != config.Secrets[i].Format &&
+			(helper.StringInSlice(constants.DocumentFormats[:], format) || helper.StringInSlice(constants.DocumentFormats[:], config.Secrets[i].Format)) {
+			errors = append(errors, fmt.Sprintf("Secret #%d uses a different format than the other secrets with the same destination", i))
+		}
+
+		destinationFormats[destination] = config.Secrets[i].Format
 	}
 
 	if len(errors) > 0 {
@@ -102,6 +216,34 @@ func validateConfig(config Config) {
 	}
 }
 
+func validateAuthMethod(config Config, errors *[]string) {
+	if !helper.StringInSlice(constants.ValidAuthMethods[:], config.AuthMethod) {
+		*errors = append(*errors, "Invalid auth method: "+config.AuthMethod)
+		return
+	}
+
+	switch config.AuthMethod {
+	case constants.AuthMethodKubernetes, constants.AuthMethodJwt:
+		if "" == config.Role {
+			*errors = append(*errors, "No role set")
+		}
+
+		if !helper.FileExists(config.TokenPath) {
+			*errors = append(*errors, "Token file does not exist at "+config.TokenPath)
+		}
+	case constants.AuthMethodAppRole:
+		if "" == config.RoleIdPath {
+			*errors = append(*errors, "No role ID path set")
+		} else if !helper.FileExists(config.RoleIdPath) {
+			*errors = append(*errors, "Role ID file does not exist at "+config.RoleIdPath)
+		}
+
+		if "" != config.SecretIdPath && !helper.FileExists(config.SecretIdPath) {
+			*errors = append(*errors, "Secret ID file does not exist at "+config.SecretIdPath)
+		}
+	}
+}
+
 func prepareAndValidateDataDir(dataDir string, errors *[]string) {
 	if "" == dataDir {
 		*errors = append(*errors, "No data directory defined")
@@ -130,7 +272,8 @@ func validateSecret(secret *SecretDefinition, i int, errors *[]string) {
 		*errors = append(*errors, fmt.Sprintf("No name for secret #%d", i))
 	}
 
-	if "" == secret.Source && secret.Origin != constants.OriginToken {
+	if "" == secret.Source &&
+		!helper.StringInSlice([]string{constants.OriginToken, constants.OriginEnv, constants.OriginStatic}, secret.Origin) {
 		*errors = append(*errors, fmt.Sprintf("No source for secret #%d", i))
 	}
 
@@ -143,21 +286,302 @@ func validateSecret(secret *SecretDefinition, i int, errors *[]string) {
 	}
 
 	if 0 == secret.FileMode {
-		secret.FileMode = 0644
+		// A generated SSH private key must not be readable by others
+		if constants.OriginSsh == secret.Origin && (nil == secret.Ssh || "" == secret.Ssh.PublicKeyFile) {
+			secret.FileMode = 0600
+		} else {
+			secret.FileMode = 0644
+		}
 	}
 
 	if !helper.StringInSlice(constants.ValidFormats[:], secret.Format) {
 		*errors = append(*errors, fmt.Sprintf("Invalid format #%d: %s", i, secret.Format))
 	}
 
+	if secret.Format == constants.FormatTemplate {
+		validateTemplate(secret, i, errors)
+	} else if "" != secret.Template || "" != secret.TemplateFile {
+		*errors = append(*errors, fmt.Sprintf("Template set for secret #%d, but it doesn't use the template format", i))
+	}
+
+	if "" == secret.DotenvDialect {
+		secret.DotenvDialect = constants.DotenvDialectGoQuote
+	} else if !helper.StringInSlice(constants.ValidDotenvDialects[:], secret.DotenvDialect) {
+		*errors = append(*errors, fmt.Sprintf("Invalid dotenv dialect for secret #%d: %s", i, secret.DotenvDialect))
+	} else if secret.Format != constants.FormatDotenv && secret.DotenvDialect != constants.DotenvDialectGoQuote {
+		*errors = append(*errors, fmt.Sprintf("Dotenv dialect set for secret #%d, but it doesn't use the dotenv format", i))
+	}
+
+	if secret.TruncateOnPopulate &&
+		!helper.StringInSlice([]string{constants.FormatDotenv, constants.FormatShell, constants.FormatProperties}, secret.Format) {
+		*errors = append(*errors, fmt.Sprintf("Truncate on populate set for secret #%d, but only dotenv, shell and properties secrets can be truncated", i))
+	}
+
+	if secret.Format == constants.FormatKeystore {
+		validateKeystore(secret, i, errors)
+	} else if nil != secret.Keystore {
+		*errors = append(*errors, fmt.Sprintf("Keystore options set for secret #%d, but it doesn't use the keystore format", i))
+	}
+
+	if secret.ShellAllExport && secret.Format != constants.FormatShell {
+		*errors = append(*errors, fmt.Sprintf("Shell all export set for secret #%d, but it doesn't use the shell format", i))
+	}
+
+	if secret.NestUnderName && !helper.StringInSlice(constants.DocumentFormats[:], secret.Format) {
+		*errors = append(*errors, fmt.Sprintf("Nest under name set for secret #%d, but only json and yaml secrets can be nested", i))
+	}
+
 	for _, decoder := range secret.Decoders {
 		if !helper.StringInSlice(constants.ValidDecoders[:], decoder) {
 			*errors = append(*errors, fmt.Sprintf("Invalid decoder #%d: %s", i, decoder))
 		}
 	}
+
+	if nil != secret.Notify {
+		validateNotify(*secret.Notify, i, errors)
+	}
+
+	if secret.RefreshInterval < 0 {
+		*errors = append(*errors, fmt.Sprintf("Invalid refresh interval for secret #%d: %d", i, secret.RefreshInterval))
+	} else if secret.RefreshInterval > 0 && secret.Origin != constants.OriginVault && secret.Origin != constants.OriginKv {
+		*errors = append(*errors, fmt.Sprintf("Refresh interval set for secret #%d, but only vault and kv secrets can be refreshed", i))
+	}
+
+	if nil != secret.List {
+		validateList(secret, i, errors)
+	}
+
+	if len(secret.Parameters) > 0 &&
+		!helper.StringInSlice([]string{constants.OriginVaultWrite, constants.OriginPki, constants.OriginSsh}, secret.Origin) {
+		*errors = append(*errors, fmt.Sprintf("Parameters set for secret #%d, but only vault-write, pki and ssh secrets use them", i))
+	}
+
+	if secret.Origin == constants.OriginSsh {
+		validateSsh(secret, i, errors)
+	} else if nil != secret.Ssh {
+		*errors = append(*errors, fmt.Sprintf("SSH options set for secret #%d, but it doesn't use the ssh origin", i))
+	}
+
+	if secret.Origin == constants.OriginTransit {
+		validateTransit(secret.Transit, i, errors)
+	} else if nil != secret.Transit {
+		*errors = append(*errors, fmt.Sprintf("Transit options set for secret #%d, but it doesn't use the transit origin", i))
+	}
+
+	if secret.Origin == constants.OriginEnv && (nil == secret.Env || (len(secret.Env.Variables) == 0 && "" == secret.Env.Prefix)) {
+		*errors = append(*errors, fmt.Sprintf("No environment variables or prefix set for secret #%d", i))
+	} else if secret.Origin != constants.OriginEnv && nil != secret.Env {
+		*errors = append(*errors, fmt.Sprintf("Environment options set for secret #%d, but it doesn't use the env origin", i))
+	}
+
+	if secret.Origin == constants.OriginStatic && len(secret.Values) == 0 {
+		*errors = append(*errors, fmt.Sprintf("No values set for secret #%d", i))
+	} else if secret.Origin != constants.OriginStatic && len(secret.Values) > 0 {
+		*errors = append(*errors, fmt.Sprintf("Values set for secret #%d, but it doesn't use the static origin", i))
+	}
+
+	if "" == secret.SourceFormat {
+		secret.SourceFormat = constants.SourceFormatRaw
+	} else if !helper.StringInSlice(constants.ValidSourceFormats[:], secret.SourceFormat) {
+		*errors = append(*errors, fmt.Sprintf("Invalid source format for secret #%d: %s", i, secret.SourceFormat))
+	} else if secret.SourceFormat != constants.SourceFormatRaw && secret.Origin != constants.OriginFile {
+		*errors = append(*errors, fmt.Sprintf("Source format set for secret #%d, but only file secrets can be parsed", i))
+	}
+
+	if secret.Origin == constants.OriginRandom {
+		validateRandom(secret, i, errors)
+	} else if nil != secret.Random {
+		*errors = append(*errors, fmt.Sprintf("Random options set for secret #%d, but it doesn't use the random origin", i))
+	}
+
+	if nil != secret.Directory {
+		validateDirectory(secret, i, errors)
+	}
+
+	if secret.Origin == constants.OriginPki && (nil == secret.Pki || "" == secret.Pki.CommonName) {
+		*errors = append(*errors, fmt.Sprintf("No PKI common name set for secret #%d", i))
+	} else if secret.Origin != constants.OriginPki && nil != secret.Pki {
+		*errors = append(*errors, fmt.Sprintf("PKI options set for secret #%d, but it doesn't use the pki origin", i))
+	}
+
+	if secret.Version < 0 {
+		*errors = append(*errors, fmt.Sprintf("Invalid version for secret #%d: %d", i, secret.Version))
+	} else if secret.Version > 0 && secret.Origin != constants.OriginKv {
+		*errors = append(*errors, fmt.Sprintf("Version set for secret #%d, but only kv secrets can have a version", i))
+	}
+}
+
+func validateList(secret *SecretDefinition, i int, errors *[]string) {
+	if secret.Origin != constants.OriginKv {
+		*errors = append(*errors, fmt.Sprintf("List set for secret #%d, but only kv secrets can be listed", i))
+	}
+
+	if secret.Version > 0 {
+		*errors = append(*errors, fmt.Sprintf("Both list and version set for secret #%d", i))
+	}
+
+	if "" == secret.List.KeyNaming {
+		secret.List.KeyNaming = constants.ListKeyNamingKey
+	} else if !helper.StringInSlice(constants.ValidListKeyNamings[:], secret.List.KeyNaming) {
+		*errors = append(*errors, fmt.Sprintf("Invalid list key naming for secret #%d: %s", i, secret.List.KeyNaming))
+	}
+
+	if "" == secret.List.Separator {
+		secret.List.Separator = "_"
+	}
+
+	if "" == secret.List.Collision {
+		secret.List.Collision = constants.ListCollisionError
+	} else if !helper.StringInSlice(constants.ValidListCollisions[:], secret.List.Collision) {
+		*errors = append(*errors, fmt.Sprintf("Invalid list collision policy for secret #%d: %s", i, secret.List.Collision))
+	}
+}
+
+func validateTransit(transit *TransitDefinition, i int, errors *[]string) {
+	if nil == transit || ("" == transit.CiphertextFile && len(transit.Ciphertexts) == 0) {
+		*errors = append(*errors, fmt.Sprintf("No ciphertext file or ciphertexts set for secret #%d", i))
+		return
+	}
+
+	if "" != transit.CiphertextFile && !helper.FileExists(transit.CiphertextFile) {
+		*errors = append(*errors, fmt.Sprintf("Ciphertext file does not exist for secret #%d: %s", i, transit.CiphertextFile))
+	}
+}
+
+func validateKeystore(secret *SecretDefinition, i int, errors *[]string) {
+	if nil == secret.Keystore {
+		secret.Keystore = &KeystoreDefinition{}
+	}
+
+	if "" == secret.Keystore.Type {
+		secret.Keystore.Type = constants.KeystoreTypePkcs12
+	} else if !helper.StringInSlice(constants.ValidKeystoreTypes[:], secret.Keystore.Type) {
+		*errors = append(*errors, fmt.Sprintf("Invalid keystore type for secret #%d: %s", i, secret.Keystore.Type))
+	}
+
+	if "" == secret.Keystore.CertificateKey {
+		secret.Keystore.CertificateKey = "tls.crt"
+	}
+
+	if "" == secret.Keystore.PrivateKeyKey {
+		secret.Keystore.PrivateKeyKey = "tls.key"
+	}
+
+	if "" == secret.Keystore.CaKey {
+		secret.Keystore.CaKey = "ca.crt"
+	}
+
+	if "" == secret.Keystore.Alias {
+		secret.Keystore.Alias = "certificate"
+	}
+
+	if "" == secret.Keystore.PasswordKey && "" == secret.Keystore.PasswordFile {
+		*errors = append(*errors, fmt.Sprintf("No keystore password key or password file set for secret #%d", i))
+	} else if "" != secret.Keystore.PasswordKey && "" != secret.Keystore.PasswordFile {
+		*errors = append(*errors, fmt.Sprintf("Both keystore password key and password file set for secret #%d", i))
+	} else if "" != secret.Keystore.PasswordFile && !helper.FileExists(secret.Keystore.PasswordFile) {
+		*errors = append(*errors, fmt.Sprintf("Keystore password file does not exist for secret #%d: %s", i, secret.Keystore.PasswordFile))
+	}
+}
+
+func validateTemplate(secret *SecretDefinition, i int, errors *[]string) {
+	if "" == secret.Template && "" == secret.TemplateFile {
+		*errors = append(*errors, fmt.Sprintf("No template or template file set for secret #%d", i))
+	} else if "" != secret.Template && "" != secret.TemplateFile {
+		*errors = append(*errors, fmt.Sprintf("Both template and template file set for secret #%d", i))
+	} else if "" != secret.TemplateFile && !helper.FileExists(secret.TemplateFile) {
+		*errors = append(*errors, fmt.Sprintf("Template file does not exist for secret #%d: %s", i, secret.TemplateFile))
+	}
+}
+
+func validateRandom(secret *SecretDefinition, i int, errors *[]string) {
+	if nil == secret.Random || len(secret.Random.Keys) == 0 {
+		*errors = append(*errors, fmt.Sprintf("No random keys set for secret #%d", i))
+		return
+	}
+
+	if "" != secret.SecretBaseKey {
+		*errors = append(*errors, fmt.Sprintf("Secret base key set for secret #%d, but random secrets can't use it", i))
+	}
+
+	if secret.Random.Length < 0 || secret.Random.Bytes < 0 {
+		*errors = append(*errors, fmt.Sprintf("Invalid random length for secret #%d", i))
+	}
+
+	if secret.Random.Bytes > 0 {
+		if secret.Random.Length > 0 || "" != secret.Random.Charset {
+			*errors = append(*errors, fmt.Sprintf("Both bytes and length or charset set for secret #%d", i))
+		}
+
+		return
+	}
+
+	if 0 == secret.Random.Length {
+		secret.Random.Length = 32
+	}
+
+	if "" == secret.Random.Charset {
+		secret.Random.Charset = constants.RandomCharsetAlphanumeric
+	} else if _, ok := constants.RandomCharsets[secret.Random.Charset]; !ok {
+		*errors = append(*errors, fmt.Sprintf("Invalid random charset for secret #%d: %s", i, secret.Random.Charset))
+	}
+}
+
+func validateDirectory(secret *SecretDefinition, i int, errors *[]string) {
+	if secret.Origin != constants.OriginDirectory {
+		*errors = append(*errors, fmt.Sprintf("Directory options set for secret #%d, but it doesn't use the directory origin", i))
+	}
+
+	for _, pattern := range append(secret.Directory.Include, secret.Directory.Exclude...) {
+		if _, err := path.Match(pattern, ""); nil != err {
+			*errors = append(*errors, fmt.Sprintf("Invalid directory pattern for secret #%d: %s", i, pattern))
+		}
+	}
+}
+
+func validateSsh(secret *SecretDefinition, i int, errors *[]string) {
+	if nil == secret.Ssh {
+		secret.Ssh = &SshDefinition{}
+	}
+
+	if "" != secret.Ssh.PublicKeyFile && !helper.FileExists(secret.Ssh.PublicKeyFile) {
+		*errors = append(*errors, fmt.Sprintf("Public key file does not exist for secret #%d: %s", i, secret.Ssh.PublicKeyFile))
+	}
+
+	if "" != secret.Ssh.CertType && "user" != secret.Ssh.CertType && "host" != secret.Ssh.CertType {
+		*errors = append(*errors, fmt.Sprintf("Invalid SSH certificate type for secret #%d: %s", i, secret.Ssh.CertType))
+	}
+}
+
+func validateNotify(notify NotifyDefinition, i int, errors *[]string) {
+	if "" == notify.Signal && len(notify.Command) == 0 && "" == notify.HttpUrl {
+		*errors = append(*errors, fmt.Sprintf("No signal, command or HTTP URL set for the notification of secret #%d", i))
+	}
+
+	if "" != notify.Signal {
+		if _, ok := constants.ValidSignals[notify.Signal]; !ok {
+			*errors = append(*errors, fmt.Sprintf("Invalid notification signal for secret #%d: %s", i, notify.Signal))
+		}
+
+		if "" == notify.ProcessName && "" == notify.PidFile {
+			*errors = append(*errors, fmt.Sprintf("No process name or pid file set for the notification signal of secret #%d", i))
+		}
+	} else if "" != notify.ProcessName || "" != notify.PidFile {
+		*errors = append(*errors, fmt.Sprintf("Process name or pid file set without a signal for the notification of secret #%d", i))
+	}
 }
 
 func populateDefaults(config *Config) {
+	// An explicit 0 disables the debounce, so the default is only set if the value is missing
+	if nil == config.NotifyDebounce {
+		notifyDebounce := 5
+		config.NotifyDebounce = &notifyDebounce
+	}
+
+	if "" == config.AuthMethod {
+		config.AuthMethod = constants.AuthMethodKubernetes
+	}
+
 	if "" == config.TokenPath {
 		config.TokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 	}
@@ -0,0 +1,11 @@
+package constants
+
+const AuthMethodKubernetes = "kubernetes"
+const AuthMethodAppRole = "approle"
+const AuthMethodJwt = "jwt"
+
+var ValidAuthMethods = [...]string{
+	AuthMethodKubernetes,
+	AuthMethodAppRole,
+	AuthMethodJwt,
+}
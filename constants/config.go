@@ -1,3 +1,4 @@
 package constants
 
 const LifetimeDivisor = 3
+const RenewalJitterDivisor = 10
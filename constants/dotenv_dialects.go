@@ -0,0 +1,17 @@
+package constants
+
+const DotenvDialectGoQuote = "go-quote"
+const DotenvDialectSingleQuoted = "single-quoted"
+const DotenvDialectDockerRaw = "docker-raw"
+const DotenvDialectSystemd = "systemd"
+const DotenvDialectPhpDotenv = "php-dotenv"
+const DotenvDialectShellExport = "shell-export"
+
+var ValidDotenvDialects = [...]string{
+	DotenvDialectGoQuote,
+	DotenvDialectSingleQuoted,
+	DotenvDialectDockerRaw,
+	DotenvDialectSystemd,
+	DotenvDialectPhpDotenv,
+	DotenvDialectShellExport,
+}
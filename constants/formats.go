@@ -2,8 +2,26 @@ package constants
 
 const FormatDotenv = "dotenv"
 const FormatFile = "file"
+const FormatTemplate = "template"
+const FormatJson = "json"
+const FormatYaml = "yaml"
+const FormatShell = "shell"
+const FormatProperties = "properties"
+const FormatKeystore = "keystore"
 
 var ValidFormats = [...]string{
 	FormatDotenv,
 	FormatFile,
+	FormatTemplate,
+	FormatJson,
+	FormatYaml,
+	FormatShell,
+	FormatProperties,
+	FormatKeystore,
+}
+
+// DocumentFormats are the formats that merge every secret with the same destination into one document
+var DocumentFormats = [...]string{
+	FormatJson,
+	FormatYaml,
 }
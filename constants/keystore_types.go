@@ -0,0 +1,11 @@
+package constants
+
+const KeystoreTypePkcs12 = "pkcs12"
+const KeystoreTypePkcs12Legacy = "pkcs12-legacy"
+const KeystoreTypeJks = "jks"
+
+var ValidKeystoreTypes = [...]string{
+	KeystoreTypePkcs12,
+	KeystoreTypePkcs12Legacy,
+	KeystoreTypeJks,
+}
@@ -0,0 +1,19 @@
+package constants
+
+const ListKeyNamingKey = "key"
+const ListKeyNamingPath = "path"
+
+var ValidListKeyNamings = [...]string{
+	ListKeyNamingKey,
+	ListKeyNamingPath,
+}
+
+const ListCollisionError = "error"
+const ListCollisionFirst = "first"
+const ListCollisionLast = "last"
+
+var ValidListCollisions = [...]string{
+	ListCollisionError,
+	ListCollisionFirst,
+	ListCollisionLast,
+}
@@ -2,9 +2,11 @@ package constants
 
 const ModePopulate = "populate"
 const ModeKeepAlive = "keep-alive"
+const ModeExec = "exec"
 
 var ValidModes = [...]string{
 	"",
 	ModePopulate,
 	ModeKeepAlive,
+	ModeExec,
 }
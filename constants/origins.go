@@ -3,9 +3,27 @@ package constants
 const OriginFile = "file"
 const OriginVault = "vault"
 const OriginToken = "token"
+const OriginKv = "kv"
+const OriginPki = "pki"
+const OriginVaultWrite = "vault-write"
+const OriginTransit = "transit"
+const OriginSsh = "ssh"
+const OriginEnv = "env"
+const OriginStatic = "static"
+const OriginDirectory = "directory"
+const OriginRandom = "random"
 
 var ValidOrigins = [...]string{
 	OriginFile,
 	OriginVault,
 	OriginToken,
+	OriginKv,
+	OriginPki,
+	OriginVaultWrite,
+	OriginTransit,
+	OriginSsh,
+	OriginEnv,
+	OriginStatic,
+	OriginDirectory,
+	OriginRandom,
 }
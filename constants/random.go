@@ -0,0 +1,15 @@
+package constants
+
+const RandomCharsetAlphanumeric = "alphanumeric"
+const RandomCharsetAlpha = "alpha"
+const RandomCharsetNumeric = "numeric"
+const RandomCharsetHex = "hex"
+const RandomCharsetPrintable = "printable"
+
+var RandomCharsets = map[string]string{
+	RandomCharsetAlphanumeric: "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+	RandomCharsetAlpha:        "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+	RandomCharsetNumeric:      "0123456789",
+	RandomCharsetHex:          "0123456789abcdef",
+	RandomCharsetPrintable:    "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!#$%&()*+,-./:;<=>?@[]^_{|}~",
+}
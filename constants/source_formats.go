@@ -0,0 +1,13 @@
+package constants
+
+const SourceFormatRaw = "raw"
+const SourceFormatDotenv = "dotenv"
+const SourceFormatJson = "json"
+const SourceFormatYaml = "yaml"
+
+var ValidSourceFormats = [...]string{
+	SourceFormatRaw,
+	SourceFormatDotenv,
+	SourceFormatJson,
+	SourceFormatYaml,
+}
@@ -1,6 +1,8 @@
 package data
 
 import (
+	"errors"
+	"fmt"
 	"github.com/golang/glog"
 	"github.com/hashicorp/vault/api"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/helper"
@@ -11,64 +13,125 @@ import (
 )
 
 type SavedData struct {
-	CreationTimestamp int          `yaml:"creationTimestamp"`
-	LoginToken        string       `yaml:"LoginToken"`
-	AuthLeaseDuration int          `yaml:"AuthLeaseDuration"`
-	Secrets           []api.Secret `yaml:"secrets"`
+	CreationTimestamp        int           `yaml:"creationTimestamp"`
+	LoginToken               string        `yaml:"LoginToken"`
+	AuthLeaseDuration        int           `yaml:"AuthLeaseDuration"`
+	NextAuthRenewalTimestamp int           `yaml:"nextAuthRenewalTimestamp"`
+	Secrets                  []SavedSecret `yaml:"secrets"`
+	// The keys written to the json and yaml destinations by each secret, indexed by the destination and the secret name
+	DocumentKeys map[string]map[string][]string `yaml:"documentKeys,omitempty"`
 }
 
-func (s *SavedData) GetShortestExpirationSeconds() int {
-	shortest := s.AuthLeaseDuration
+type SavedSecret struct {
+	Name                 string `yaml:"name"`
+	LeaseTimestamp       int    `yaml:"leaseTimestamp"`
+	NextRenewalTimestamp int    `yaml:"nextRenewalTimestamp"`
+	NextRefreshTimestamp int    `yaml:"nextRefreshTimestamp"`
+	NextReissueTimestamp int    `yaml:"nextReissueTimestamp"`
+	ExpirationTimestamp  int    `yaml:"expirationTimestamp"`
+	ContentHash          string `yaml:"contentHash"`
+	Version              int    `yaml:"version"`
+	api.Secret           `yaml:",inline"`
+}
+
+func (s *SavedData) GetAuthExpirationTimestamp() int {
+	return s.CreationTimestamp + s.AuthLeaseDuration
+}
+
+func (s *SavedData) GetSecret(name string) (api.Secret, bool) {
+	savedSecret := s.GetSavedSecret(name)
+
+	if nil == savedSecret {
+		return api.Secret{}, false
+	}
 
-	for _, secretData := range s.Secrets {
-		if secretData.Renewable && secretData.LeaseDuration > 0 && shortest > secretData.LeaseDuration {
-			shortest = secretData.LeaseDuration
+	return savedSecret.Secret, true
+}
+
+func (s *SavedData) GetSavedSecret(name string) *SavedSecret {
+	for key := range s.Secrets {
+		if s.Secrets[key].Name == name {
+			return &s.Secrets[key]
 		}
 	}
 
-	return shortest
+	return nil
+}
+
+func (s *SavedData) SetSecret(name string, secret api.Secret) {
+	savedSecret := s.GetSavedSecret(name)
+
+	if nil == savedSecret {
+		s.Secrets = append(s.Secrets, SavedSecret{Name: name})
+		savedSecret = &s.Secrets[len(s.Secrets)-1]
+	}
+
+	savedSecret.Secret = secret
+	savedSecret.LeaseTimestamp = int(time.Now().UTC().Unix())
+	savedSecret.NextRenewalTimestamp = 0
+	savedSecret.NextRefreshTimestamp = 0
+	savedSecret.NextReissueTimestamp = 0
+	savedSecret.ExpirationTimestamp = 0
 }
 
-func (s *SavedData) GetTimeOfShortestExpiration() time.Time {
-	return time.Unix(int64(s.CreationTimestamp+s.GetShortestExpirationSeconds()), 0)
+func (s *SavedData) GetDocumentKeys(destination string, name string) []string {
+	return s.DocumentKeys[destination][name]
 }
 
-func Load(basePath string) SavedData {
+func (s *SavedData) SetDocumentKeys(destination string, name string, keys []string) {
+	if nil == s.DocumentKeys {
+		s.DocumentKeys = map[string]map[string][]string{}
+	}
+
+	if nil == s.DocumentKeys[destination] {
+		s.DocumentKeys[destination] = map[string][]string{}
+	}
+
+	s.DocumentKeys[destination][name] = keys
+}
+
+func (s *SavedSecret) GetExpirationTimestamp() int {
+	return s.LeaseTimestamp + s.LeaseDuration
+}
+
+func Load(basePath string) (SavedData, error) {
 	filePath := getFilePath(basePath)
 	savedData := SavedData{}
 
 	if !helper.FileExists(filePath) {
-		glog.Exit("Data file does not exist")
+		return savedData, errors.New("data file does not exist")
 	}
 
 	yamlContents, err := ioutil.ReadFile(filePath)
 
 	if err != nil {
-		glog.Exit("Failed to load the data file: ", err)
+		return savedData, fmt.Errorf("failed to load the data file: %w", err)
 	}
 
 	err = yaml.Unmarshal(yamlContents, &savedData)
 
 	if err != nil {
-		glog.Exit("Failed to parse the data file as YAML. ", err)
+		return savedData, fmt.Errorf("failed to parse the data file as YAML: %w", err)
 	}
 
-	return savedData
+	return savedData, nil
 }
 
-func Save(basePath string, data SavedData) {
+func Save(basePath string, data SavedData) error {
 	filePath := getFilePath(basePath)
 	yamlContents, err := yaml.Marshal(data)
 
 	if err != nil {
-		glog.Exit("Failed to create yaml data: ", err)
+		return fmt.Errorf("failed to create yaml data: %w", err)
 	}
 
 	err = ioutil.WriteFile(filePath, yamlContents, 0644)
 
 	if err != nil {
-		glog.Exit("Failed to write data file: ", err)
+		return fmt.Errorf("failed to write data file: %w", err)
 	}
+
+	return nil
 }
 
 func Clear(basePath string) {
@@ -0,0 +1,151 @@
+package formatter
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const dataDirectoryLinkPrefix = "..data-"
+
+// writeFileAtomically writes the content to a temporary file in the same directory, then renames it over the
+// destination, so readers either see the old or the new content, but never a partially written file. The mode of an
+// existing destination file is kept.
+func writeFileAtomically(destination string, content []byte, mode os.FileMode) error {
+	if fileInfo, err := os.Stat(destination); nil == err {
+		mode = fileInfo.Mode().Perm()
+	}
+
+	tempPath := path.Join(path.Dir(destination), fmt.Sprintf(".%s.%d.%d.tmp", path.Base(destination), os.Getpid(), time.Now().UnixNano()))
+	f, err := os.OpenFile(tempPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+
+	if nil != err {
+		return err
+	}
+
+	_, err = f.Write(content)
+
+	if nil == err {
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); nil == err {
+		err = closeErr
+	}
+
+	if nil == err {
+		err = os.Chmod(tempPath, mode)
+	}
+
+	if nil == err {
+		err = os.Rename(tempPath, destination)
+	}
+
+	if nil != err {
+		os.Remove(tempPath)
+	}
+
+	return err
+}
+
+// writeDirectoryAtomically writes the files into a new timestamped directory inside the destination, then swaps the
+// ..data-<name> symlink of the secret to it, the same way the Kubernetes AtomicWriter updates mounted Secrets and
+// ConfigMaps. Every file of the secret in the destination is a symlink pointing into its data link, so all the files of
+// the secret change together. As every secret has its own data link, multiple secrets can share a destination.
+func writeDirectoryAtomically(destination string, name string, files map[string][]byte, directoryMode os.FileMode, fileMode os.FileMode) error {
+	dataDirectoryLink := getDataDirectoryLink(name)
+	previousDirectory, _ := os.Readlink(path.Join(destination, dataDirectoryLink))
+	dataDirectory := ".." + time.Now().UTC().Format("2006_01_02_15_04_05.000000000") + "-" + getDataDirectorySuffix(name)
+
+	if err := os.Mkdir(path.Join(destination, dataDirectory), directoryMode); nil != err {
+		return err
+	}
+
+	err := writeDataDirectoryFiles(path.Join(destination, dataDirectory), files, fileMode)
+
+	if nil == err {
+		err = replaceWithSymlink(dataDirectory, path.Join(destination, dataDirectoryLink))
+	}
+
+	// The new data directory is only in use after the data link points to it
+	if nil != err {
+		os.RemoveAll(path.Join(destination, dataDirectory))
+		return err
+	}
+
+	for fileName := range files {
+		if err := replaceWithSymlink(path.Join(dataDirectoryLink, fileName), path.Join(destination, fileName)); nil != err {
+			return err
+		}
+	}
+
+	removeStaleFiles(destination, dataDirectoryLink, files, previousDirectory)
+
+	return nil
+}
+
+func writeDataDirectoryFiles(dataDirectory string, files map[string][]byte, fileMode os.FileMode) error {
+	for fileName, content := range files {
+		if err := ioutil.WriteFile(path.Join(dataDirectory, fileName), content, fileMode); nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getDataDirectoryLink(name string) string {
+	return dataDirectoryLinkPrefix + getDataDirectorySuffix(name)
+}
+
+// The secret name is used in file names, so path separators are replaced in it
+func getDataDirectorySuffix(name string) string {
+	return strings.ReplaceAll(name, "/", "_")
+}
+
+func replaceWithSymlink(target string, linkPath string) error {
+	if existingTarget, err := os.Readlink(linkPath); nil == err && existingTarget == target {
+		return nil
+	}
+
+	tempPath := linkPath + ".tmp"
+	os.Remove(tempPath)
+
+	if err := os.Symlink(target, tempPath); nil != err {
+		return err
+	}
+
+	return os.Rename(tempPath, linkPath)
+}
+
+// Removes the previous data directory and the symlinks of the files that are no longer in the secret. Symlinks pointing
+// into the data links of other secrets are left untouched.
+func removeStaleFiles(destination string, dataDirectoryLink string, files map[string][]byte, previousDirectory string) {
+	if strings.HasPrefix(previousDirectory, "..") && !strings.Contains(previousDirectory, "/") {
+		if err := os.RemoveAll(path.Join(destination, previousDirectory)); nil != err {
+			glog.Warning("Failed to remove the previous data directory "+previousDirectory+": ", err)
+		}
+	}
+
+	entries, err := ioutil.ReadDir(destination)
+
+	if nil != err {
+		return
+	}
+
+	for _, entry := range entries {
+		if _, ok := files[entry.Name()]; ok || entry.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		target, err := os.Readlink(path.Join(destination, entry.Name()))
+
+		if nil == err && strings.HasPrefix(target, dataDirectoryLink+"/") {
+			os.Remove(path.Join(destination, entry.Name()))
+		}
+	}
+}
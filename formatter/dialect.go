@@ -0,0 +1,112 @@
+package formatter
+
+import (
+	"errors"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type dotenvDialect struct {
+	keyPattern *regexp.Regexp
+	formatLine func(key string, value string) (string, error)
+}
+
+var environmentVariableKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+var dottedKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+var dotenvDialects = map[string]dotenvDialect{
+	// Go escapes, like \n, \t and é, in double quotes
+	constants.DotenvDialectGoQuote: {
+		keyPattern: regexp.MustCompile(`^[^=#\s][^=\s]*$`),
+		formatLine: func(key string, value string) (string, error) {
+			return key + "=" + strconv.Quote(value), nil
+		},
+	},
+	// Literal values in single quotes, which may span multiple lines, but can't contain single quotes
+	constants.DotenvDialectSingleQuoted: {
+		keyPattern: dottedKeyPattern,
+		formatLine: func(key string, value string) (string, error) {
+			if strings.Contains(value, "'") {
+				return "", errors.New("the value contains a single quote")
+			}
+
+			return key + "='" + value + "'", nil
+		},
+	},
+	// Docker --env-file uses everything after the = as the value without any unquoting, so it can't be multi line
+	constants.DotenvDialectDockerRaw: {
+		keyPattern: regexp.MustCompile(`^[^=#\s][^=\s]*$`),
+		formatLine: func(key string, value string) (string, error) {
+			if strings.ContainsAny(value, "\r\n") {
+				return "", errors.New("the value contains a line break")
+			}
+
+			return key + "=" + value, nil
+		},
+	},
+	// systemd EnvironmentFile only unescapes \", \\, \` and \$ in double quotes, and allows literal line breaks in them
+	constants.DotenvDialectSystemd: {
+		keyPattern: environmentVariableKeyPattern,
+		formatLine: func(key string, value string) (string, error) {
+			return key + "=\"" + escapeCharacters(value, "\"\\`$") + "\"", nil
+		},
+	},
+	// vlucas/phpdotenv unescapes \", \\, \$ and the whitespace escapes in double quotes, and expands ${VAR} otherwise
+	constants.DotenvDialectPhpDotenv: {
+		keyPattern: dottedKeyPattern,
+		formatLine: func(key string, value string) (string, error) {
+			value = escapeCharacters(value, "\"\\$")
+			value = strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(value)
+
+			return key + "=\"" + value + "\"", nil
+		},
+	},
+	// POSIX shell, where nothing is special in single quotes, so single quotes are closed, escaped and opened again
+	constants.DotenvDialectShellExport: {
+		keyPattern: environmentVariableKeyPattern,
+		formatLine: func(key string, value string) (string, error) {
+			return "export " + key + "=" + quoteShellValue(value), nil
+		},
+	},
+}
+
+func formatDotenvLine(dialectName string, key string, value string) (string, error) {
+	dialect := dotenvDialects[dialectName]
+
+	if !dialect.keyPattern.MatchString(key) {
+		return "", errors.New("the key " + strconv.Quote(key) + " is not valid for the " + dialectName + " dotenv dialect")
+	}
+
+	// Only go-quote escapes NUL characters, the other dialects would write them to the file as they are
+	if constants.DotenvDialectGoQuote != dialectName && strings.ContainsRune(value, 0) {
+		return "", errors.New("the value of " + key + " can't be written in the " + dialectName + " dotenv dialect: the value contains a NUL character")
+	}
+
+	line, err := dialect.formatLine(key, value)
+
+	if nil != err {
+		return "", errors.New("the value of " + key + " can't be written in the " + dialectName + " dotenv dialect: " + err.Error())
+	}
+
+	return line, nil
+}
+
+func quoteShellValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func escapeCharacters(value string, characters string) string {
+	var escaped strings.Builder
+
+	for _, character := range value {
+		if strings.ContainsRune(characters, character) {
+			escaped.WriteRune('\\')
+		}
+
+		escaped.WriteRune(character)
+	}
+
+	return escaped.String()
+}
@@ -0,0 +1,121 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/data"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/decoder"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/helper"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+)
+
+func formatDocumentSecret(secretData map[string]string, definition config.SecretDefinition, dec *decoder.Decoder, savedData *data.SavedData) error {
+	decodedSecretData, err := getDecodedSecretData(secretData, definition, dec)
+
+	if nil != err {
+		return err
+	}
+
+	document := map[string]interface{}{}
+
+	if !isFirstWriteOfPopulation(definition.Destination) && helper.FileExists(definition.Destination) {
+		document, err = readDocument(definition)
+
+		if nil != err {
+			return fmt.Errorf("failed to read the existing destination file for secret %s: %w", definition.Name, err)
+		}
+	}
+
+	if definition.NestUnderName {
+		nestedDocument := map[string]interface{}{}
+
+		for key, value := range decodedSecretData {
+			nestedDocument[key] = value
+		}
+
+		document[definition.Name] = nestedDocument
+	} else {
+		removeDroppedDocumentKeys(document, decodedSecretData, definition, savedData)
+
+		for key, value := range decodedSecretData {
+			document[key] = value
+		}
+
+		savedData.SetDocumentKeys(definition.Destination, definition.Name, getSortedKeys(decodedSecretData))
+	}
+
+	content, err := encodeDocument(document, definition.Format)
+
+	if nil != err {
+		return fmt.Errorf("failed to encode the document for secret %s: %w", definition.Name, err)
+	}
+
+	if err = createDestinationParentDirectory(definition); nil != err {
+		return err
+	}
+
+	err = writeFileAtomically(definition.Destination, content, definition.FileMode)
+
+	if err != nil {
+		return fmt.Errorf("failed to write to destination file for secret %s: %w", definition.Name, err)
+	}
+
+	return nil
+}
+
+// Removes the keys the secret wrote previously but no longer has, unless another secret writes the same key to the
+// destination
+func removeDroppedDocumentKeys(document map[string]interface{}, decodedSecretData map[string]string, definition config.SecretDefinition, savedData *data.SavedData) {
+	for _, key := range savedData.GetDocumentKeys(definition.Destination, definition.Name) {
+		if _, ok := decodedSecretData[key]; ok || isDocumentKeyWrittenByOtherSecret(key, definition, savedData) {
+			continue
+		}
+
+		delete(document, key)
+	}
+}
+
+func isDocumentKeyWrittenByOtherSecret(key string, definition config.SecretDefinition, savedData *data.SavedData) bool {
+	for name, keys := range savedData.DocumentKeys[definition.Destination] {
+		if name != definition.Name && helper.StringInSlice(keys, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readDocument(definition config.SecretDefinition) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(definition.Destination)
+
+	if nil != err {
+		return nil, err
+	}
+
+	document := map[string]interface{}{}
+
+	if constants.FormatJson == definition.Format {
+		err = json.Unmarshal(content, &document)
+	} else {
+		err = yaml.Unmarshal(content, &document)
+	}
+
+	return document, err
+}
+
+func encodeDocument(document map[string]interface{}, format string) ([]byte, error) {
+	if constants.FormatYaml == format {
+		return yaml.Marshal(document)
+	}
+
+	content, err := json.MarshalIndent(document, "", "  ")
+
+	if nil != err {
+		return nil, err
+	}
+
+	return append(content, '\n'), nil
+}
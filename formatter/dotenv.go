@@ -0,0 +1,119 @@
+package formatter
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/helper"
+	"io/ioutil"
+	"strings"
+)
+
+// writeSection writes the lines into the marked section of the secret in the destination file. The section is replaced
+// on every write, so the other content of the file is left untouched and writing the same secret again doesn't
+// duplicate the keys.
+func writeSection(definition config.SecretDefinition, lines []string) error {
+	if err := createDestinationParentDirectory(definition); nil != err {
+		return err
+	}
+
+	headerText := "Secret source: " + definition.Name
+	section := []string{strings.Repeat("#", len(headerText)+4), "# " + headerText + " #", strings.Repeat("#", len(headerText)+4)}
+	section = append(append(section, lines...), getDotenvSectionEndMarker(definition.Name))
+
+	existingContent := ""
+
+	if isFirstWriteOfPopulation(definition.Destination) && truncatedDestinations[definition.Destination] {
+		glog.V(1).Info("Truncating destination file " + definition.Destination)
+	} else if helper.FileExists(definition.Destination) {
+		content, err := ioutil.ReadFile(definition.Destination)
+
+		if err != nil {
+			return fmt.Errorf("failed to read destination file for secret %s: %w", definition.Name, err)
+		}
+
+		existingContent = string(content)
+	}
+
+	err := writeFileAtomically(definition.Destination, []byte(replaceDotenvSection(existingContent, definition.Name, section)), definition.FileMode)
+
+	if err != nil {
+		return fmt.Errorf("failed to write to destination file for secret %s: %w", definition.Name, err)
+	}
+
+	return nil
+}
+
+// replaceDotenvSection replaces the section of the secret in the content with the given lines, or appends it to the
+// end if the content has no section for the secret yet. Sections written by earlier versions have no end marker, so
+// they end at the start of the next section or at the end of the content.
+func replaceDotenvSection(content string, name string, section []string) string {
+	var lines []string
+
+	if "" != content {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+
+	result := []string{}
+	isReplaced := false
+
+	for i := 0; i < len(lines); {
+		if sectionName, ok := getDotenvSectionName(lines, i); !ok || sectionName != name {
+			result = append(result, lines[i])
+			i++
+			continue
+		}
+
+		if isReplaced {
+			// A duplicate of the section, so it's removed along with the empty line before it
+			if len(result) > 0 && "" == result[len(result)-1] {
+				result = result[:len(result)-1]
+			}
+		} else {
+			result = append(result, section...)
+			isReplaced = true
+		}
+
+		i = getDotenvSectionEnd(lines, i, name)
+	}
+
+	if !isReplaced {
+		result = append(append(result, ""), section...)
+	}
+
+	return strings.Join(result, "\n") + "\n"
+}
+
+func getDotenvSectionName(lines []string, i int) (string, bool) {
+	if i+2 >= len(lines) || len(lines[i]) < 5 || strings.Trim(lines[i], "#") != "" || lines[i+2] != lines[i] {
+		return "", false
+	}
+
+	if !strings.HasPrefix(lines[i+1], "# Secret source: ") || !strings.HasSuffix(lines[i+1], " #") {
+		return "", false
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(lines[i+1], "# Secret source: "), " #"), true
+}
+
+func getDotenvSectionEnd(lines []string, start int, name string) int {
+	for i := start + 3; i < len(lines); i++ {
+		if lines[i] == getDotenvSectionEndMarker(name) {
+			return i + 1
+		}
+
+		if _, ok := getDotenvSectionName(lines, i); ok {
+			if "" == lines[i-1] && i-1 >= start+3 {
+				return i - 1
+			}
+
+			return i
+		}
+	}
+
+	return len(lines)
+}
+
+func getDotenvSectionEndMarker(name string) string {
+	return "# End of secret source: " + name
+}
@@ -0,0 +1,48 @@
+package formatter
+
+import (
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"sort"
+	"sync"
+)
+
+var environments = map[string]map[string]string{}
+var environmentsMutex sync.Mutex
+
+func setEnvironment(definition config.SecretDefinition, environment map[string]string) {
+	environmentsMutex.Lock()
+	defer environmentsMutex.Unlock()
+
+	environments[definition.Name] = environment
+}
+
+// GetEnvironment returns the values of the written dotenv format secrets in KEY=value format. The secrets are processed
+// in the order of the definitions, so a key in a later secret overrides the same key in an earlier one.
+func GetEnvironment(definitions []config.SecretDefinition) []string {
+	environmentsMutex.Lock()
+	defer environmentsMutex.Unlock()
+
+	environment := []string{}
+
+	for _, definition := range definitions {
+		values, ok := environments[definition.Name]
+
+		if !ok {
+			continue
+		}
+
+		keys := make([]string, 0, len(values))
+
+		for key := range values {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			environment = append(environment, key+"="+values[key])
+		}
+	}
+
+	return environment
+}
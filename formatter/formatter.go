@@ -1,104 +1,155 @@
 package formatter
 
 import (
+	"errors"
+	"fmt"
 	"github.com/golang/glog"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/data"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/decoder"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/helper"
-	"io/ioutil"
 	"os"
 	"path"
-	"strconv"
-	"strings"
+	"sort"
 )
 
-func FormatSecret(secretData map[string]string, definition config.SecretDefinition) {
+// The saved data is used to keep track of the keys written to the json and yaml documents
+func FormatSecret(secretData map[string]string, definition config.SecretDefinition, savedData *data.SavedData) error {
 	glog.Info("Writing secret " + definition.Name)
 	dec, err := decoder.New(definition)
 
 	if nil != err {
-		glog.Exit("Failed to create decoder for secret " + definition.Name)
+		return fmt.Errorf("failed to create decoder for secret %s: %w", definition.Name, err)
 	}
 
 	switch definition.Format {
 	case constants.FormatFile:
-		formatFileSecret(secretData, definition, dec)
+		return formatFileSecret(secretData, definition, dec)
 	case constants.FormatDotenv:
-		formatDotenvSecret(secretData, definition, dec)
+		return formatDotenvSecret(secretData, definition, dec)
+	case constants.FormatProperties:
+		return formatPropertiesSecret(secretData, definition, dec)
+	case constants.FormatKeystore:
+		return formatKeystoreSecret(secretData, definition, dec)
+	case constants.FormatShell:
+		return formatShellSecret(secretData, definition, dec)
+	case constants.FormatTemplate:
+		return formatTemplateSecret(secretData, definition, dec)
+	case constants.FormatJson, constants.FormatYaml:
+		return formatDocumentSecret(secretData, definition, dec, savedData)
 	default:
-		glog.Exit("Invalid format: " + definition.Format)
+		return errors.New("invalid format: " + definition.Format)
 	}
 }
 
-func formatDotenvSecret(secretData map[string]string, definition config.SecretDefinition, dec *decoder.Decoder) {
-	headerText := "Secret source: " + definition.Name
-	stringToWrite := "\n" + strings.Repeat("#", len(headerText)+4) + "\n# " + headerText + " #\n" + strings.Repeat("#", len(headerText)+4) + "\n"
+func formatDotenvSecret(secretData map[string]string, definition config.SecretDefinition, dec *decoder.Decoder) error {
+	environment, err := getDecodedSecretData(secretData, definition, dec)
 
-	if !helper.FileExists(path.Dir(definition.Destination)) {
-		err := os.MkdirAll(path.Dir(definition.Destination), definition.DirectoryMode)
+	if nil != err {
+		return err
+	}
 
-		if err != nil {
-			glog.Exit("Failed to crate destination directory for secret "+definition.Name+": ", err)
+	lines := []string{}
+
+	for _, key := range getSortedKeys(environment) {
+		line, err := formatDotenvLine(definition.DotenvDialect, key, environment[key])
+
+		if nil != err {
+			return fmt.Errorf("failed to format secret %s: %w", definition.Name, err)
 		}
+
+		lines = append(lines, line)
 	}
 
-	for key, value := range mapSecretData(secretData, definition) {
-		decodedValue, err := dec.DecodeString(value)
+	setEnvironment(definition, environment)
 
-		if nil != err {
-			glog.Exitf("Failed to decode value for %s in secret %s: %v", key, definition.Name, err)
+	return writeSection(definition, lines)
+}
+
+func formatFileSecret(secretData map[string]string, definition config.SecretDefinition, dec *decoder.Decoder) error {
+	if !helper.FileExists(definition.Destination) {
+		err := os.MkdirAll(definition.Destination, definition.DirectoryMode)
+
+		if err != nil {
+			return fmt.Errorf("failed to create destination directory for secret %s: %w", definition.Name, err)
 		}
+	}
 
-		stringToWrite = stringToWrite + key + "=" + strconv.Quote(string(decodedValue)) + "\n"
+	if !helper.IsDir(definition.Destination) {
+		return errors.New("the destination is not a directory for secret " + definition.Name)
 	}
 
-	f, err := os.OpenFile(definition.Destination, os.O_APPEND|os.O_CREATE|os.O_WRONLY, definition.FileMode)
+	decodedSecretData, err := getDecodedSecretData(secretData, definition, dec)
 
-	if err != nil {
-		glog.Exit("Failed to open destination file for writing for secret "+definition.Name+": ", err)
+	if nil != err {
+		return err
 	}
 
-	defer f.Close()
+	files := map[string][]byte{}
 
-	_, err = f.WriteString(stringToWrite)
+	for key, value := range decodedSecretData {
+		files[key] = []byte(value)
+	}
+
+	err = writeDirectoryAtomically(definition.Destination, definition.Name, files, definition.DirectoryMode, definition.FileMode)
 
 	if err != nil {
-		glog.Exit("Failed to write to destination file for secret "+definition.Name+": ", err)
+		return fmt.Errorf("failed to write files for secret %s: %w", definition.Name, err)
 	}
+
+	return nil
 }
 
-func formatFileSecret(secretData map[string]string, definition config.SecretDefinition, dec *decoder.Decoder) {
-	if !helper.FileExists(definition.Destination) {
-		err := os.MkdirAll(definition.Destination, definition.DirectoryMode)
+func createDestinationParentDirectory(definition config.SecretDefinition) error {
+	if !helper.FileExists(path.Dir(definition.Destination)) {
+		err := os.MkdirAll(path.Dir(definition.Destination), definition.DirectoryMode)
 
 		if err != nil {
-			glog.Exit("Failed to create destination directory for secret " + definition.Name)
+			return fmt.Errorf("failed to create destination directory for secret %s: %w", definition.Name, err)
 		}
 	}
 
-	if !helper.IsDir(definition.Destination) {
-		glog.Exit("The destination is not a directory for secret " + definition.Name)
+	return nil
+}
+
+func getDecodedSecretData(secretData map[string]string, definition config.SecretDefinition, dec *decoder.Decoder) (map[string]string, error) {
+	mappedSecretData, err := mapSecretData(secretData, definition)
+
+	if nil != err {
+		return nil, err
 	}
 
-	for key, value := range mapSecretData(secretData, definition) {
+	decodedSecretData := map[string]string{}
+
+	for key, value := range mappedSecretData {
 		decodedValue, err := dec.DecodeString(value)
 
 		if nil != err {
-			glog.Exitf("Failed to decode value for %s in secret %s: %v", key, definition.Name, err)
+			return nil, fmt.Errorf("failed to decode value for %s in secret %s: %w", key, definition.Name, err)
 		}
 
-		err = ioutil.WriteFile(definition.Destination+"/"+key, decodedValue, definition.FileMode)
+		decodedSecretData[key] = string(decodedValue)
+	}
 
-		if err != nil {
-			glog.Exit("Failed to write file "+key+" for secret "+definition.Name+": ", err)
-		}
+	return decodedSecretData, nil
+}
+
+func getSortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+
+	for key := range values {
+		keys = append(keys, key)
 	}
+
+	sort.Strings(keys)
+
+	return keys
 }
 
-func mapSecretData(secretData map[string]string, definition config.SecretDefinition) map[string]string {
+func mapSecretData(secretData map[string]string, definition config.SecretDefinition) (map[string]string, error) {
 	if len(definition.Mapping) == 0 {
-		return secretData
+		return secretData, nil
 	}
 
 	newSecretData := map[string]string{}
@@ -107,11 +158,11 @@ func mapSecretData(secretData map[string]string, definition config.SecretDefinit
 		mappedValue, ok := secretData[value]
 
 		if !ok {
-			glog.Exit("Mapping failed in secret " + definition.Name + ". Key " + value + " doesn't exist in secret data")
+			return nil, errors.New("mapping failed in secret " + definition.Name + ". Key " + value + " doesn't exist in secret data")
 		}
 
 		newSecretData[key] = mappedValue
 	}
 
-	return newSecretData
+	return newSecretData, nil
 }
@@ -0,0 +1,257 @@
+package formatter
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/decoder"
+	"io/ioutil"
+	"software.sslmate.com/src/go-pkcs12"
+	"strings"
+	"time"
+)
+
+func formatKeystoreSecret(secretData map[string]string, definition config.SecretDefinition, dec *decoder.Decoder) error {
+	values, err := getDecodedSecretData(secretData, definition, dec)
+
+	if nil != err {
+		return err
+	}
+
+	content, err := buildKeystore(values, *definition.Keystore)
+
+	if nil != err {
+		return fmt.Errorf("failed to build the keystore for secret %s: %w", definition.Name, err)
+	}
+
+	if err = createDestinationParentDirectory(definition); nil != err {
+		return err
+	}
+
+	err = writeFileAtomically(definition.Destination, content, definition.FileMode)
+
+	if err != nil {
+		return fmt.Errorf("failed to write to destination file for secret %s: %w", definition.Name, err)
+	}
+
+	return nil
+}
+
+func buildKeystore(values map[string]string, definition config.KeystoreDefinition) ([]byte, error) {
+	certificates, err := parseCertificates(values, definition.CertificateKey)
+
+	if nil != err {
+		return nil, err
+	}
+
+	privateKey, err := parsePrivateKey(values, definition.PrivateKeyKey)
+
+	if nil != err {
+		return nil, err
+	}
+
+	if err = checkPrivateKeyMatchesCertificate(privateKey, certificates[0]); nil != err {
+		return nil, err
+	}
+
+	password, err := getKeystorePassword(values, definition)
+
+	if nil != err {
+		return nil, err
+	}
+
+	// The CA is optional, and the certificates already in the chain are skipped
+	if _, ok := values[definition.CaKey]; ok {
+		caCertificates, err := parseCertificates(values, definition.CaKey)
+
+		if nil != err {
+			return nil, err
+		}
+
+		for _, caCertificate := range caCertificates {
+			if !containsCertificate(certificates, caCertificate) {
+				certificates = append(certificates, caCertificate)
+			}
+		}
+	}
+
+	switch definition.Type {
+	case constants.KeystoreTypeJks:
+		return buildJks(privateKey, certificates, password, definition.Alias)
+	case constants.KeystoreTypePkcs12Legacy:
+		return pkcs12.Legacy.Encode(privateKey, certificates[0], certificates[1:], password)
+	default:
+		return pkcs12.Modern.Encode(privateKey, certificates[0], certificates[1:], password)
+	}
+}
+
+func buildJks(privateKey interface{}, certificates []*x509.Certificate, password string, alias string) ([]byte, error) {
+	encodedPrivateKey, err := x509.MarshalPKCS8PrivateKey(privateKey)
+
+	if nil != err {
+		return nil, err
+	}
+
+	entry := keystore.PrivateKeyEntry{
+		CreationTime: time.Now(),
+		PrivateKey:   encodedPrivateKey,
+	}
+
+	for _, certificate := range certificates {
+		entry.CertificateChain = append(entry.CertificateChain, keystore.Certificate{Type: "X509", Content: certificate.Raw})
+	}
+
+	ks := keystore.New()
+
+	if err = ks.SetPrivateKeyEntry(alias, entry, []byte(password)); nil != err {
+		return nil, err
+	}
+
+	var content bytes.Buffer
+
+	if err = ks.Store(&content, []byte(password)); nil != err {
+		return nil, err
+	}
+
+	return content.Bytes(), nil
+}
+
+func getKeystorePassword(values map[string]string, definition config.KeystoreDefinition) (string, error) {
+	if "" != definition.PasswordFile {
+		password, err := ioutil.ReadFile(definition.PasswordFile)
+
+		if nil != err {
+			return "", err
+		}
+
+		return strings.TrimSpace(string(password)), nil
+	}
+
+	password, ok := values[definition.PasswordKey]
+
+	if !ok {
+		return "", errors.New("the password key " + definition.PasswordKey + " doesn't exist in the secret")
+	}
+
+	return password, nil
+}
+
+// The certificates are accepted both in PEM and in DER format
+func parseCertificates(values map[string]string, key string) ([]*x509.Certificate, error) {
+	blocks, err := getPemOrDerBlocks(values, key)
+
+	if nil != err {
+		return nil, err
+	}
+
+	var certificates []*x509.Certificate
+
+	for _, block := range blocks {
+		parsedCertificates, err := x509.ParseCertificates(block.Bytes)
+
+		if nil != err {
+			return nil, errors.New("failed to parse the certificate in " + key + ": " + err.Error())
+		}
+
+		certificates = append(certificates, parsedCertificates...)
+	}
+
+	if len(certificates) == 0 {
+		return nil, errors.New("no certificate found in " + key)
+	}
+
+	return certificates, nil
+}
+
+// Other PEM blocks, like the EC PARAMETERS block written by openssl before an EC key, are skipped
+func parsePrivateKey(values map[string]string, key string) (interface{}, error) {
+	blocks, err := getPemOrDerBlocks(values, key)
+
+	if nil != err {
+		return nil, err
+	}
+
+	for _, block := range blocks {
+		if "" != block.Type && !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+
+		if privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes); nil == err {
+			return privateKey, nil
+		}
+
+		if privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); nil == err {
+			return privateKey, nil
+		}
+
+		if privateKey, err := x509.ParseECPrivateKey(block.Bytes); nil == err {
+			return privateKey, nil
+		}
+
+		break
+	}
+
+	return nil, errors.New("failed to parse the private key in " + key)
+}
+
+// The private key is stored in the same entry as the first certificate, so it must belong to that certificate
+func checkPrivateKeyMatchesCertificate(privateKey interface{}, certificate *x509.Certificate) error {
+	signer, ok := privateKey.(crypto.Signer)
+
+	if !ok {
+		return errors.New("the private key has an unsupported type")
+	}
+
+	publicKey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+
+	if !ok || !publicKey.Equal(certificate.PublicKey) {
+		return errors.New("the private key doesn't match the certificate")
+	}
+
+	return nil
+}
+
+// A value that isn't in PEM format is returned as a single DER block without a type
+func getPemOrDerBlocks(values map[string]string, key string) ([]*pem.Block, error) {
+	value, ok := values[key]
+
+	if !ok {
+		return nil, errors.New("the key " + key + " doesn't exist in the secret")
+	}
+
+	var blocks []*pem.Block
+	rest := []byte(value)
+
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+
+		if nil == block {
+			break
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	if len(blocks) == 0 {
+		blocks = append(blocks, &pem.Block{Bytes: []byte(value)})
+	}
+
+	return blocks, nil
+}
+
+func containsCertificate(certificates []*x509.Certificate, certificate *x509.Certificate) bool {
+	for _, existingCertificate := range certificates {
+		if existingCertificate.Equal(certificate) {
+			return true
+		}
+	}
+
+	return false
+}
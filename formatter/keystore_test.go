@@ -0,0 +1,85 @@
+package formatter
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var keystoreTestDefinition = config.KeystoreDefinition{
+	Type:           constants.KeystoreTypePkcs12,
+	CertificateKey: "certificate",
+	PrivateKeyKey:  "private_key",
+	PasswordKey:    "password",
+}
+
+func TestBuildKeystoreSkipsEcParametersBlock(t *testing.T) {
+	privateKey, certificate := generateKeystoreTestCertificate(t)
+	encodedKey, err := x509.MarshalECPrivateKey(privateKey)
+
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// openssl ecparam -genkey writes the curve parameters before the key
+	ecParameters := pem.EncodeToMemory(&pem.Block{Type: "EC PARAMETERS", Bytes: []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}})
+	values := map[string]string{
+		"certificate": certificate,
+		"private_key": string(ecParameters) + string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: encodedKey})),
+		"password":    "changeit",
+	}
+
+	if _, err := buildKeystore(values, keystoreTestDefinition); nil != err {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildKeystoreRejectsMismatchedPrivateKey(t *testing.T) {
+	_, certificate := generateKeystoreTestCertificate(t)
+	otherPrivateKey, _ := generateKeystoreTestCertificate(t)
+	encodedKey, err := x509.MarshalPKCS8PrivateKey(otherPrivateKey)
+
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	values := map[string]string{
+		"certificate": certificate,
+		"private_key": string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encodedKey})),
+		"password":    "changeit",
+	}
+
+	if _, err := buildKeystore(values, keystoreTestDefinition); nil == err {
+		t.Error("The private key of another certificate was accepted")
+	}
+}
+
+func generateKeystoreTestCertificate(t *testing.T) (*ecdsa.PrivateKey, string) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	return privateKey, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}))
+}
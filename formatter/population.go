@@ -0,0 +1,32 @@
+package formatter
+
+import "github.com/szeber/vault-kubernetes-dotenv-manager/config"
+
+// The destinations written since the start of the population, or nil if no population was started by this process
+var populatedDestinations map[string]bool
+
+// The destinations with at least one secret setting truncateOnPopulate
+var truncatedDestinations map[string]bool
+
+// StartPopulation marks the start of populating every secret. The first write to a destination after this starts the
+// file from scratch, while every other write, like the re-renders while keeping the secrets alive, updates the file.
+func StartPopulation(secrets []config.SecretDefinition) {
+	populatedDestinations = map[string]bool{}
+	truncatedDestinations = map[string]bool{}
+
+	for _, definition := range secrets {
+		if definition.TruncateOnPopulate {
+			truncatedDestinations[definition.Destination] = true
+		}
+	}
+}
+
+func isFirstWriteOfPopulation(destination string) bool {
+	if nil == populatedDestinations || populatedDestinations[destination] {
+		return false
+	}
+
+	populatedDestinations[destination] = true
+
+	return true
+}
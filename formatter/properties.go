@@ -0,0 +1,65 @@
+package formatter
+
+import (
+	"fmt"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/decoder"
+	"strings"
+	"unicode/utf16"
+)
+
+func formatPropertiesSecret(secretData map[string]string, definition config.SecretDefinition, dec *decoder.Decoder) error {
+	values, err := getDecodedSecretData(secretData, definition, dec)
+
+	if nil != err {
+		return err
+	}
+
+	lines := []string{}
+
+	for _, key := range getSortedKeys(values) {
+		lines = append(lines, escapeProperty(key, true)+"="+escapeProperty(values[key], false))
+	}
+
+	return writeSection(definition, lines)
+}
+
+// escapeProperty escapes the same way as java.util.Properties.store(). Every non-ASCII character is written as a \u
+// escape, so the file can be read with the ISO-8859-1 encoding used by Properties.load(InputStream) as well.
+func escapeProperty(value string, isKey bool) string {
+	var escaped strings.Builder
+
+	for i, character := range value {
+		switch character {
+		case '\\':
+			escaped.WriteString(`\\`)
+		case '\t':
+			escaped.WriteString(`\t`)
+		case '\n':
+			escaped.WriteString(`\n`)
+		case '\r':
+			escaped.WriteString(`\r`)
+		case '\f':
+			escaped.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			escaped.WriteRune('\\')
+			escaped.WriteRune(character)
+		case ' ':
+			if isKey || 0 == i {
+				escaped.WriteRune('\\')
+			}
+
+			escaped.WriteRune(character)
+		default:
+			if character < 0x20 || character > 0x7e {
+				for _, unit := range utf16.Encode([]rune{character}) {
+					escaped.WriteString(fmt.Sprintf(`\u%04X`, unit))
+				}
+			} else {
+				escaped.WriteRune(character)
+			}
+		}
+	}
+
+	return escaped.String()
+}
@@ -0,0 +1,40 @@
+package formatter
+
+import (
+	"errors"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/decoder"
+)
+
+// The values are written in single quotes, where the shell doesn't expand anything, so the file can be safely sourced
+func formatShellSecret(secretData map[string]string, definition config.SecretDefinition, dec *decoder.Decoder) error {
+	values, err := getDecodedSecretData(secretData, definition, dec)
+
+	if nil != err {
+		return err
+	}
+
+	lines := []string{}
+
+	if definition.ShellAllExport {
+		lines = append(lines, "set -a")
+	}
+
+	for _, key := range getSortedKeys(values) {
+		if !environmentVariableKeyPattern.MatchString(key) {
+			return errors.New("failed to format secret " + definition.Name + ": the key " + key + " is not a valid shell variable name")
+		}
+
+		if definition.ShellAllExport {
+			lines = append(lines, key+"="+quoteShellValue(values[key]))
+		} else {
+			lines = append(lines, "export "+key+"="+quoteShellValue(values[key]))
+		}
+	}
+
+	if definition.ShellAllExport {
+		lines = append(lines, "set +a")
+	}
+
+	return writeSection(definition, lines)
+}
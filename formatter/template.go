@@ -0,0 +1,90 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/decoder"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"text/template"
+)
+
+var templateFunctions = template.FuncMap{
+	"quote": strconv.Quote,
+	"base64": func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	},
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+
+		return string(encoded), err
+	},
+	"default": func(defaultValue string, value string) string {
+		if "" == value {
+			return defaultValue
+		}
+
+		return value
+	},
+	"required": func(message string, value string) (string, error) {
+		if "" == value {
+			return "", errors.New(message)
+		}
+
+		return value, nil
+	},
+}
+
+func formatTemplateSecret(secretData map[string]string, definition config.SecretDefinition, dec *decoder.Decoder) error {
+	tmpl, err := parseTemplate(definition)
+
+	if nil != err {
+		return fmt.Errorf("failed to parse the template for secret %s: %w", definition.Name, err)
+	}
+
+	values, err := getDecodedSecretData(secretData, definition, dec)
+
+	if nil != err {
+		return err
+	}
+
+	var rendered bytes.Buffer
+
+	// Missing keys render as empty strings, so they can be handled by the default and required functions
+	err = tmpl.Option("missingkey=zero").Execute(&rendered, values)
+
+	if nil != err {
+		return fmt.Errorf("failed to render the template for secret %s: %w", definition.Name, err)
+	}
+
+	if err = createDestinationParentDirectory(definition); nil != err {
+		return err
+	}
+
+	err = writeFileAtomically(definition.Destination, rendered.Bytes(), definition.FileMode)
+
+	if err != nil {
+		return fmt.Errorf("failed to write to destination file for secret %s: %w", definition.Name, err)
+	}
+
+	return nil
+}
+
+func parseTemplate(definition config.SecretDefinition) (*template.Template, error) {
+	if "" == definition.TemplateFile {
+		return template.New(definition.Name).Funcs(templateFunctions).Parse(definition.Template)
+	}
+
+	content, err := ioutil.ReadFile(definition.TemplateFile)
+
+	if nil != err {
+		return nil, err
+	}
+
+	return template.New(path.Base(definition.TemplateFile)).Funcs(templateFunctions).Parse(string(content))
+}
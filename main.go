@@ -13,9 +13,10 @@ import (
 )
 
 var configPath = flag.String("config", "config.yaml", "The path to the config file")
-var mode = flag.String("mode", "", "The operating mode. Optional. Valid values are 'populate' or 'keep-alive'. Defaults to doing both operations")
+var mode = flag.String("mode", "", "The operating mode. Optional. Valid values are 'populate', 'keep-alive' or 'exec'. Defaults to doing both populate and keep-alive")
 var httpPort = flag.Int("http-port", 8000, "The HTTP port for liveness and readiness checks")
 var waitAfterPopulationSeconds = flag.Int("wait-after-population", 0, "The number of seconds to wait after populating the secrets before exiting or going into keep-alive mode")
+var restartOnChange = flag.Bool("restart-on-change", false, "In exec mode restart the command when a secret is rewritten")
 
 func main() {
 	// Set default values
@@ -33,6 +34,11 @@ func main() {
 		os.Exit(0)
 	}
 
+	if constants.ModeExec == *mode && 0 == flag.NArg() {
+		flag.Usage()
+		glog.Exit("No command set for exec mode")
+	}
+
 	appConfig := config.LoadConfig(*configPath)
 
 	switch *mode {
@@ -45,6 +51,9 @@ func main() {
 	case constants.ModeKeepAlive:
 		revokeAuthLeaseOnQuit(appConfig)
 		secret_manager.KeepSecretsAlive(appConfig, *httpPort)
+	case constants.ModeExec:
+		secret_manager.PopulateSecrets(appConfig, *waitAfterPopulationSeconds)
+		secret_manager.RunCommand(appConfig, *httpPort, flag.Args(), *restartOnChange)
 	default:
 		glog.Exit("Invalid operating mode: " + *mode)
 	}
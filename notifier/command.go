@@ -0,0 +1,19 @@
+package notifier
+
+import (
+	"github.com/golang/glog"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"os/exec"
+)
+
+func runCommand(definition config.NotifyDefinition) error {
+	glog.Info("Running notification command ", definition.Command)
+
+	output, err := exec.Command(definition.Command[0], definition.Command[1:]...).CombinedOutput()
+
+	if len(output) > 0 {
+		glog.Info("Notification command output: ", string(output))
+	}
+
+	return err
+}
@@ -0,0 +1,36 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"net/http"
+	"time"
+)
+
+func sendHttpRequest(definition config.NotifyDefinition, secretNames []string) error {
+	body, err := json.Marshal(map[string]interface{}{"secrets": secretNames})
+
+	if err != nil {
+		return err
+	}
+
+	glog.Info("Sending notification to " + definition.HttpUrl)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	response, err := client.Post(definition.HttpUrl, "application/json", bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package notifier
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"sort"
+	"sync"
+	"time"
+)
+
+type pendingNotification struct {
+	definition  config.NotifyDefinition
+	secretNames []string
+}
+
+var debounceDuration time.Duration
+var pendingNotifications = map[string]*pendingNotification{}
+var hasPendingChanges bool
+var changeListeners []func()
+var timer *time.Timer
+var mutex sync.Mutex
+
+func SetDebounceSeconds(seconds int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	debounceDuration = time.Duration(seconds) * time.Second
+}
+
+// OnChange registers a listener that is called once after the notifications of the changed secrets have been sent
+func OnChange(listener func()) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	changeListeners = append(changeListeners, listener)
+}
+
+// Notify queues the notifications of the secret. The notifications are sent once no other secret changed for the
+// debounce duration, and identical notifications of multiple secrets are only sent once.
+func Notify(definition config.SecretDefinition) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	hasPendingChanges = true
+
+	if nil != definition.Notify {
+		key := fmt.Sprintf("%+v", *definition.Notify)
+		notification, ok := pendingNotifications[key]
+
+		if !ok {
+			notification = &pendingNotification{definition: *definition.Notify}
+			pendingNotifications[key] = notification
+		}
+
+		notification.secretNames = append(notification.secretNames, definition.Name)
+
+		glog.V(1).Infof("Queued notification for secret %s, sending it in %v", definition.Name, debounceDuration)
+	}
+
+	if nil != timer {
+		timer.Stop()
+	}
+
+	timer = time.AfterFunc(debounceDuration, sendPendingNotifications)
+}
+
+func sendPendingNotifications() {
+	mutex.Lock()
+	notifications := pendingNotifications
+	pendingNotifications = map[string]*pendingNotification{}
+	hasChanges := hasPendingChanges
+	hasPendingChanges = false
+	listeners := changeListeners
+	timer = nil
+	mutex.Unlock()
+
+	keys := make([]string, 0, len(notifications))
+
+	for key := range notifications {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		send(notifications[key])
+	}
+
+	if hasChanges {
+		for _, listener := range listeners {
+			listener()
+		}
+	}
+}
+
+func send(notification *pendingNotification) {
+	glog.Info("Sending notifications for the changed secrets: ", notification.secretNames)
+	definition := notification.definition
+
+	if "" != definition.Signal {
+		if err := sendSignal(definition); err != nil {
+			glog.Error("Failed to send signal: ", err)
+		}
+	}
+
+	if len(definition.Command) > 0 {
+		if err := runCommand(definition); err != nil {
+			glog.Error("Failed to run notification command: ", err)
+		}
+	}
+
+	if "" != definition.HttpUrl {
+		if err := sendHttpRequest(definition, notification.secretNames); err != nil {
+			glog.Error("Failed to send notification HTTP request: ", err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package notifier
+
+import (
+	"bytes"
+	"errors"
+	"github.com/golang/glog"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/helper"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+func sendSignal(definition config.NotifyDefinition) error {
+	signal, ok := constants.ValidSignals[definition.Signal]
+
+	if !ok {
+		return errors.New("Invalid signal: " + definition.Signal)
+	}
+
+	pids, err := getPids(definition)
+
+	if err != nil {
+		return err
+	}
+
+	if len(pids) == 0 {
+		return errors.New("No process found to send the signal to")
+	}
+
+	for _, pid := range pids {
+		glog.Infof("Sending %s to process %d", definition.Signal, pid)
+
+		if err := syscall.Kill(pid, signal); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getPids(definition config.NotifyDefinition) ([]int, error) {
+	if "" != definition.PidFile {
+		pidFileContents, err := ioutil.ReadFile(definition.PidFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pid, err := strconv.Atoi(strings.TrimSpace(string(pidFileContents)))
+
+		if err != nil {
+			return nil, errors.New("Invalid pid in pid file " + definition.PidFile)
+		}
+
+		return []int{pid}, nil
+	}
+
+	return findPidsByProcessName(definition.ProcessName)
+}
+
+// Processes are looked up by their name in /proc, so the process namespace must be shared with the application. If
+// both a process and its parent match, like the master and worker processes of php-fpm or nginx, only the parent is
+// returned.
+func findPidsByProcessName(processName string) ([]int, error) {
+	entries, err := ioutil.ReadDir("/proc")
+
+	if err != nil {
+		return nil, err
+	}
+
+	matchingPids := map[int]bool{}
+	ownPid := os.Getpid()
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+
+		if err != nil || pid == ownPid {
+			continue
+		}
+
+		if helper.StringInSlice(getProcessNames(pid), processName) {
+			matchingPids[pid] = true
+		}
+	}
+
+	pids := []int{}
+
+	for pid := range matchingPids {
+		if !matchingPids[getParentPid(pid)] {
+			pids = append(pids, pid)
+		}
+	}
+
+	sort.Ints(pids)
+
+	return pids, nil
+}
+
+// A process can be matched by the name of its executable, its comm name or its argv[0]. Daemons like php-fpm and nginx
+// rewrite their argv, so argv[0] alone is not enough.
+func getProcessNames(pid int) []string {
+	procPath := "/proc/" + strconv.Itoa(pid)
+	names := []string{}
+
+	if cmdline, err := ioutil.ReadFile(procPath + "/cmdline"); err == nil && len(cmdline) > 0 {
+		names = append(names, path.Base(strings.SplitN(string(cmdline), "\x00", 2)[0]))
+	}
+
+	if comm, err := ioutil.ReadFile(procPath + "/comm"); err == nil {
+		names = append(names, strings.TrimSpace(string(comm)))
+	}
+
+	if executable, err := os.Readlink(procPath + "/exe"); err == nil {
+		names = append(names, path.Base(strings.TrimSuffix(executable, " (deleted)")))
+	}
+
+	return names
+}
+
+func getParentPid(pid int) int {
+	stat, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+
+	if err != nil {
+		return 0
+	}
+
+	// The comm name in parentheses may contain spaces, so the fields are read after its closing parenthesis
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+
+	if len(fields) < 2 {
+		return 0
+	}
+
+	parentPid, _ := strconv.Atoi(fields[1])
+
+	return parentPid
+}
@@ -1,6 +1,7 @@
 package secret_manager
 
 import (
+	"fmt"
 	"github.com/golang/glog"
 	"github.com/hashicorp/vault/api"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
@@ -13,33 +14,61 @@ var apiClientAuthLifetimeSeconds int
 var apiClientAuthLifetime time.Time
 var apiClient *api.Client
 
-func getClient(appConfig config.Config, existingToken string) *api.Client {
+// Without an existing token it logs in to Vault, and exits if that fails
+func getClient(appConfig config.Config, existingToken string) (*api.Client, error) {
 	if isCurrentApiClientValid() {
-		return apiClient
+		return apiClient, nil
 	}
 
 	if "" == existingToken {
-		return makeClient(appConfig)
+		return makeClient(appConfig), nil
 	}
 
-	var err error
-	apiClient, err = vault.GetClientWithToken(appConfig, existingToken)
+	newApiClient, err := vault.GetClientWithToken(appConfig, existingToken)
 
 	if err != nil {
-		glog.Exit("Failed to get vault client with existing token: ", err)
+		return nil, fmt.Errorf("failed to get vault client with existing token: %w", err)
 	}
 
-	return apiClient
+	apiClient = newApiClient
+
+	return apiClient, nil
 }
 
 func makeClient(appConfig config.Config) *api.Client {
-	apiClient, apiClientAuthLifetimeSeconds = vault.LoginWithAppConfig(appConfig)
+	var leaseDuration int
+	apiClient, leaseDuration = vault.LoginWithAppConfig(appConfig)
+	setApiClientAuthLifetime(leaseDuration)
+
+	return apiClient
+}
+
+func relogin(appConfig config.Config, savedData *data.SavedData) (*api.Client, error) {
+	glog.Info("Logging in to Vault again")
+	newApiClient, leaseDuration, err := vault.TryLoginWithAppConfig(appConfig)
+
+	if nil != err {
+		return nil, err
+	}
+
+	apiClient = newApiClient
+	setApiClientAuthLifetime(leaseDuration)
+
+	savedData.LoginToken = apiClient.Token()
+	savedData.AuthLeaseDuration = leaseDuration
+	savedData.CreationTimestamp = int(time.Now().UTC().Unix())
+	savedData.NextAuthRenewalTimestamp = 0
+
+	return apiClient, nil
+}
+
+func setApiClientAuthLifetime(leaseDuration int) {
+	apiClientAuthLifetimeSeconds = leaseDuration
+
 	if 0 != apiClientAuthLifetimeSeconds {
 		apiClientAuthLifetime = time.Now().Add(time.Second * time.Duration(apiClientAuthLifetimeSeconds))
-		glog.V(1).Info("Created api client token expires at ", apiClientAuthLifetime)
+		glog.V(1).Info("Api client token expires at ", apiClientAuthLifetime)
 	}
-
-	return apiClient
 }
 
 func isCurrentApiClientValid() bool {
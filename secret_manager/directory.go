@@ -0,0 +1,70 @@
+package secret_manager
+
+import (
+	"github.com/golang/glog"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Mounted Kubernetes Secrets and ConfigMaps contain symlinks to the files in a ..data directory, so the symlinks are
+// followed and the ..* entries of the atomic writer are skipped
+func getSecretFromDirectory(definition config.SecretDefinition) map[string]string {
+	entries, err := ioutil.ReadDir(definition.Source)
+
+	if nil != err {
+		glog.Exit("Failed to read source directory "+definition.Source+" for secret "+definition.Name+": ", err)
+	}
+
+	secretData := map[string]string{}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "..") || !isDirectoryEntryIncluded(definition.Directory, entry.Name()) {
+			continue
+		}
+
+		filePath := path.Join(definition.Source, entry.Name())
+		fileInfo, err := os.Stat(filePath)
+
+		if nil != err || !fileInfo.Mode().IsRegular() {
+			glog.V(1).Info("Skipping " + filePath + " as it is not a regular file")
+			continue
+		}
+
+		fileData, err := ioutil.ReadFile(filePath)
+
+		if nil != err {
+			glog.Exit("Failed to read secret from source file: " + filePath)
+		}
+
+		secretData[entry.Name()] = string(fileData)
+	}
+
+	return secretData
+}
+
+func isDirectoryEntryIncluded(definition *config.DirectoryDefinition, name string) bool {
+	if nil == definition {
+		return true
+	}
+
+	for _, pattern := range definition.Exclude {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+
+	if len(definition.Include) == 0 {
+		return true
+	}
+
+	for _, pattern := range definition.Include {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,173 @@
+package secret_manager
+
+import (
+	"errors"
+	"github.com/golang/glog"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/formatter"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/notifier"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var childCommand *exec.Cmd
+var isChildRestarting bool
+var childMutex sync.Mutex
+
+// RunCommand runs the command as a child process with the dotenv format secrets in its environment, while keeping the
+// secrets alive. It exits with the exit code of the child process. If the secrets can't be kept alive, the child
+// process is stopped, and the manager exits with a non-zero exit code.
+func RunCommand(appConfig config.Config, httpPort int, args []string, restartOnChange bool) {
+	if restartOnChange {
+		notifier.OnChange(func() {
+			restartChild()
+		})
+	}
+
+	forwardSignalsToChild()
+
+	renewalErrors := make(chan error, 1)
+
+	go func() {
+		renewalErrors <- keepSecretsAlive(appConfig, httpPort)
+	}()
+
+	for {
+		cmd := startChild(appConfig, args)
+		waitErrors := make(chan error, 1)
+
+		go func() {
+			waitErrors <- cmd.Wait()
+		}()
+
+		var err error
+		var renewalErr error
+
+		select {
+		case err = <-waitErrors:
+		case renewalErr = <-renewalErrors:
+			glog.Error("Failed to keep the secrets alive, stopping the command: ", renewalErr)
+			stopChild()
+			err = <-waitErrors
+		}
+
+		childMutex.Lock()
+		isRestarting := isChildRestarting && nil == renewalErr
+		isChildRestarting = false
+		childMutex.Unlock()
+
+		if isRestarting {
+			glog.Info("Restarting the command")
+			continue
+		}
+
+		exitCode := getExitCode(err)
+		glog.Infof("The command exited with exit code %d", exitCode)
+
+		// The command stopping cleanly doesn't hide that the secrets could not be kept alive
+		if nil != renewalErr && 0 == exitCode {
+			exitCode = 1
+		}
+
+		if appConfig.RevokeAuthLeaseOnQuit {
+			glog.Info("Revoking auth lease")
+			RevokeAuthLease(appConfig)
+		}
+
+		os.Exit(exitCode)
+	}
+}
+
+func startChild(appConfig config.Config, args []string) *exec.Cmd {
+	childMutex.Lock()
+	defer childMutex.Unlock()
+
+	glog.Info("Starting command ", args)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), formatter.GetEnvironment(appConfig.Secrets)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		glog.Exit("Failed to start the command: ", err)
+	}
+
+	childCommand = cmd
+
+	return cmd
+}
+
+func restartChild() {
+	childMutex.Lock()
+	defer childMutex.Unlock()
+
+	if nil == childCommand || nil == childCommand.Process {
+		return
+	}
+
+	glog.Info("Secrets changed, stopping the command to restart it")
+	isChildRestarting = true
+
+	if err := childCommand.Process.Signal(syscall.SIGTERM); err != nil {
+		glog.Error("Failed to stop the command: ", err)
+	}
+}
+
+func stopChild() {
+	childMutex.Lock()
+	defer childMutex.Unlock()
+
+	if nil == childCommand || nil == childCommand.Process {
+		return
+	}
+
+	if err := childCommand.Process.Signal(syscall.SIGTERM); err != nil {
+		glog.Error("Failed to stop the command: ", err)
+	}
+}
+
+func forwardSignalsToChild() {
+	sigs := make(chan os.Signal, 1)
+
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		for sig := range sigs {
+			childMutex.Lock()
+
+			if nil != childCommand && nil != childCommand.Process {
+				glog.Info("Forwarding signal to the command: ", sig)
+
+				if err := childCommand.Process.Signal(sig); err != nil {
+					glog.Error("Failed to forward signal to the command: ", err)
+				}
+			}
+
+			childMutex.Unlock()
+		}
+	}()
+}
+
+func getExitCode(err error) int {
+	if nil == err {
+		return 0
+	}
+
+	var exitError *exec.ExitError
+
+	if !errors.As(err, &exitError) {
+		glog.Error("Failed to wait for the command: ", err)
+		return 1
+	}
+
+	if status, ok := exitError.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return exitError.ExitCode()
+}
@@ -3,24 +3,34 @@ package secret_manager
 import (
 	"fmt"
 	"github.com/golang/glog"
+	"net"
 	"net/http"
 )
 
 var isAlive bool
 var serverHttpPort int
 
-func startHttpServer() {
+// The port is bound before returning, so failing to listen on it is returned as an error
+func startHttpServer() error {
 	http.HandleFunc("/liveness", liveness)
 
+	listener, err := net.Listen("tcp", ":"+fmt.Sprintf("%d", serverHttpPort))
+
+	if err != nil {
+		return fmt.Errorf("failed to start HTTP server: %w", err)
+	}
+
 	glog.Info("Http server listening on " + fmt.Sprintf("%d", serverHttpPort))
 
 	go func() {
-		err := http.ListenAndServe(":"+fmt.Sprintf("%d", serverHttpPort), nil)
+		err := http.Serve(listener, nil)
 
 		if err != nil {
-			glog.Exit("Failed to start HTTP server")
+			glog.Error("The HTTP server stopped: ", err)
 		}
 	}()
+
+	return nil
 }
 
 func liveness(w http.ResponseWriter, req *http.Request) {
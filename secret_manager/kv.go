@@ -0,0 +1,209 @@
+package secret_manager
+
+import (
+	"errors"
+	"github.com/golang/glog"
+	"github.com/hashicorp/vault/api"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/vault"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var kvMounts = map[string]vault.KvMount{}
+
+func readSecretFromKv(apiClient *api.Client, definition config.SecretDefinition) (map[string]string, *api.Secret, error) {
+	mount, err := getKvMount(apiClient, definition.Source)
+
+	if nil != err {
+		return nil, nil, err
+	}
+
+	if nil != definition.List {
+		return readKvSubtree(apiClient, definition, mount)
+	}
+
+	if mount.Version == 1 && definition.Version > 0 {
+		return nil, nil, errors.New("version set for secret " + definition.Name + ", but it is in a KV version 1 mount")
+	}
+
+	sourceData, response, err := readKvData(apiClient, mount, definition.Source, definition.Version)
+
+	if nil != err {
+		return nil, nil, err
+	}
+
+	subKeyData, err := getDataForSubKey(sourceData, definition.SecretBaseKey)
+
+	if nil != err {
+		return nil, nil, err
+	}
+
+	return stringifySecretData(subKeyData), response, nil
+}
+
+func readKvData(apiClient *api.Client, mount vault.KvMount, secretPath string, version int) (map[string]interface{}, *api.Secret, error) {
+	var queryParameters map[string][]string
+
+	if version > 0 {
+		queryParameters = map[string][]string{"version": {strconv.Itoa(version)}}
+	}
+
+	dataPath := getKvPath(mount, secretPath, "data/")
+	glog.V(1).Info("Reading KV secret from " + dataPath)
+	response, err := apiClient.Logical().ReadWithData(dataPath, queryParameters)
+
+	if nil != err {
+		return nil, nil, err
+	}
+
+	if nil == response {
+		return nil, nil, errors.New("secret " + secretPath + " does not exist")
+	}
+
+	if mount.Version == 1 {
+		return response.Data, response, nil
+	}
+
+	sourceData, ok := response.Data["data"].(map[string]interface{})
+
+	if !ok {
+		return nil, nil, errors.New("secret " + secretPath + " has been deleted")
+	}
+
+	return sourceData, response, nil
+}
+
+// Every secret under the source path is read and merged into one secret. The merged secret has no lease or version, so
+// changes are only detected by the hash of its contents.
+func readKvSubtree(apiClient *api.Client, definition config.SecretDefinition, mount vault.KvMount) (map[string]string, *api.Secret, error) {
+	basePath := strings.Trim(definition.Source, "/")
+	relativePaths, err := listKvSecrets(apiClient, mount, basePath, "", definition.List.Recursive)
+
+	if nil != err {
+		return nil, nil, err
+	}
+
+	sort.Strings(relativePaths)
+	secretData := map[string]string{}
+
+	for _, relativePath := range relativePaths {
+		sourceData, _, err := readKvData(apiClient, mount, basePath+"/"+relativePath, 0)
+
+		if nil != err {
+			return nil, nil, err
+		}
+
+		subKeyData, err := getDataForSubKey(sourceData, definition.SecretBaseKey)
+
+		if nil != err {
+			return nil, nil, err
+		}
+
+		for key, value := range stringifySecretData(subKeyData) {
+			mergedKey := getListedSecretKey(*definition.List, relativePath, key)
+
+			if _, ok := secretData[mergedKey]; ok {
+				switch definition.List.Collision {
+				case constants.ListCollisionFirst:
+					continue
+				case constants.ListCollisionError:
+					return nil, nil, errors.New("key " + mergedKey + " is set by multiple secrets under " + definition.Source)
+				}
+			}
+
+			secretData[mergedKey] = value
+		}
+	}
+
+	return secretData, &api.Secret{}, nil
+}
+
+func listKvSecrets(apiClient *api.Client, mount vault.KvMount, basePath string, relativePath string, isRecursive bool) ([]string, error) {
+	listPath := getKvPath(mount, basePath+"/"+relativePath, "metadata/")
+	glog.V(1).Info("Listing KV secrets under " + listPath)
+	response, err := apiClient.Logical().List(listPath)
+
+	if nil != err {
+		return nil, err
+	}
+
+	if nil == response {
+		return nil, errors.New("there are no secrets under " + basePath + "/" + relativePath)
+	}
+
+	keys, ok := response.Data["keys"].([]interface{})
+
+	if !ok {
+		return nil, errors.New("invalid list response for " + listPath)
+	}
+
+	relativePaths := []string{}
+
+	for _, key := range keys {
+		keyString, ok := key.(string)
+
+		if !ok {
+			continue
+		}
+
+		if !strings.HasSuffix(keyString, "/") {
+			relativePaths = append(relativePaths, relativePath+keyString)
+			continue
+		}
+
+		if !isRecursive {
+			continue
+		}
+
+		childPaths, err := listKvSecrets(apiClient, mount, basePath, relativePath+keyString, isRecursive)
+
+		if nil != err {
+			return nil, err
+		}
+
+		relativePaths = append(relativePaths, childPaths...)
+	}
+
+	return relativePaths, nil
+}
+
+func getListedSecretKey(list config.ListDefinition, relativePath string, key string) string {
+	if constants.ListKeyNamingPath != list.KeyNaming {
+		return key
+	}
+
+	return strings.ReplaceAll(relativePath, "/", list.Separator) + list.Separator + key
+}
+
+func getKvMount(apiClient *api.Client, secretPath string) (vault.KvMount, error) {
+	mount, ok := kvMounts[secretPath]
+
+	if ok {
+		return mount, nil
+	}
+
+	mount, err := vault.GetKvMount(apiClient, secretPath)
+
+	if nil != err {
+		return mount, err
+	}
+
+	kvMounts[secretPath] = mount
+
+	return mount, nil
+}
+
+// The paths are rewritten to the data or metadata paths for KV version 2 mounts, so the source can be set the same way
+// as it is used with the vault CLI
+func getKvPath(mount vault.KvMount, secretPath string, version2Prefix string) string {
+	secretPath = strings.TrimLeft(secretPath, "/")
+
+	if mount.Version == 1 {
+		return secretPath
+	}
+
+	return mount.Path + version2Prefix + strings.TrimPrefix(secretPath, mount.Path)
+}
@@ -0,0 +1,83 @@
+package secret_manager
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/hashicorp/vault/api"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/data"
+	"strconv"
+	"strings"
+)
+
+func getSecretFromPki(apiClient *api.Client, definition config.SecretDefinition, savedData *data.SavedData) (map[string]string, error) {
+	secretData, response, expiration, err := issueCertificate(apiClient, definition)
+
+	if nil != err {
+		return nil, fmt.Errorf("failed to issue certificate %s for secret %s: %w", definition.Source, definition.Name, err)
+	}
+
+	response.Data = nil
+
+	savedData.SetSecret(definition.Name, *response)
+	savedData.GetSavedSecret(definition.Name).ExpirationTimestamp = expiration
+
+	return secretData, nil
+}
+
+func issueCertificate(apiClient *api.Client, definition config.SecretDefinition) (map[string]string, *api.Secret, int, error) {
+	body := getRequestParameters(definition)
+	body["common_name"] = definition.Pki.CommonName
+
+	if len(definition.Pki.AltNames) > 0 {
+		body["alt_names"] = strings.Join(definition.Pki.AltNames, ",")
+	}
+
+	if len(definition.Pki.IpSans) > 0 {
+		body["ip_sans"] = strings.Join(definition.Pki.IpSans, ",")
+	}
+
+	if "" != definition.Pki.Ttl {
+		body["ttl"] = definition.Pki.Ttl
+	}
+
+	glog.V(1).Infof("Issuing certificate for %s from %s", definition.Pki.CommonName, definition.Source)
+	response, err := apiClient.Logical().Write(definition.Source, body)
+
+	if nil != err {
+		return nil, nil, 0, err
+	}
+
+	if nil == response || nil == response.Data {
+		return nil, nil, 0, errors.New("empty response from " + definition.Source)
+	}
+
+	expiration, err := strconv.Atoi(fmt.Sprintf("%v", response.Data["expiration"]))
+
+	if nil != err {
+		return nil, nil, 0, errors.New("invalid expiration in the response from " + definition.Source)
+	}
+
+	caChain := fmt.Sprintf("%v", response.Data["issuing_ca"])
+
+	if chain, ok := response.Data["ca_chain"].([]interface{}); ok && len(chain) > 0 {
+		certificates := []string{}
+
+		for _, certificate := range chain {
+			certificates = append(certificates, fmt.Sprintf("%v", certificate))
+		}
+
+		caChain = strings.Join(certificates, "\n")
+	}
+
+	secretData := map[string]string{
+		"tls.crt": fmt.Sprintf("%v", response.Data["certificate"]) + "\n",
+		"tls.key": fmt.Sprintf("%v", response.Data["private_key"]) + "\n",
+		"ca.crt":  caChain + "\n",
+	}
+
+	glog.Infof("Issued certificate with serial number %v", response.Data["serial_number"])
+
+	return secretData, response, expiration, nil
+}
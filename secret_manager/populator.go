@@ -1,6 +1,9 @@
 package secret_manager
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/hashicorp/vault/api"
@@ -9,15 +12,25 @@ import (
 	"github.com/szeber/vault-kubernetes-dotenv-manager/data"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/formatter"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/helper"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/notifier"
 	"io/ioutil"
+	"os"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 func PopulateSecrets(appConfig config.Config, waitSeconds int) {
-	apiClient := getClient(appConfig, "")
+	apiClient, err := getClient(appConfig, "")
+
+	if nil != err {
+		glog.Exit("Failed to log in to Vault: ", err)
+	}
 
 	glog.Info("Starting secret population")
+	formatter.StartPopulation(appConfig.Secrets)
 	data.Clear(appConfig.DataDir)
 
 	dataToSave := data.SavedData{
@@ -28,23 +41,20 @@ func PopulateSecrets(appConfig config.Config, waitSeconds int) {
 
 	for _, definition := range appConfig.Secrets {
 		glog.Info("Populating secret " + definition.Name)
-		var secretData map[string]string
-
-		switch definition.Origin {
-		case constants.OriginVault:
-			secretData = getSecretFromVault(apiClient, definition, &dataToSave)
-		case constants.OriginFile:
-			secretData = getSecretFromFile(definition)
-		case constants.OriginToken:
-			secretData = map[string]string{"token": apiClient.Token()}
-		default:
-			panic("Invalid origin: " + definition.Origin)
+
+		if err := populateSecret(apiClient, definition, &dataToSave); nil != err {
+			glog.Exit("Failed to populate secret "+definition.Name+": ", err)
 		}
 
-		formatter.FormatSecret(secretData, definition)
+		if savedSecret := dataToSave.GetSavedSecret(definition.Name); definition.RefreshInterval > 0 && nil != savedSecret && "" != savedSecret.LeaseID {
+			glog.Warning("Secret " + definition.Name + " has a lease, so its refreshInterval is ignored")
+		}
+	}
+
+	if err := data.Save(appConfig.DataDir, dataToSave); nil != err {
+		glog.Exit("Failed to save the data file: ", err)
 	}
 
-	data.Save(appConfig.DataDir, dataToSave)
 	glog.Info("Finished secret population")
 
 	if waitSeconds > 0 {
@@ -54,45 +64,204 @@ func PopulateSecrets(appConfig config.Config, waitSeconds int) {
 	}
 }
 
-func getSecretFromVault(apiClient *api.Client, defintion config.SecretDefinition, savedData *data.SavedData) map[string]string {
-	response, err := apiClient.Logical().Read(defintion.Source)
+func populateSecret(apiClient *api.Client, definition config.SecretDefinition, savedData *data.SavedData) error {
+	secretData, err := getSecretData(apiClient, definition, savedData)
+
+	if nil != err {
+		return err
+	}
+
+	return formatter.FormatSecret(secretData, definition, savedData)
+}
+
+func refreshSecret(apiClient *api.Client, definition config.SecretDefinition, savedData *data.SavedData) error {
+	if err := populateSecret(apiClient, definition, savedData); nil != err {
+		return err
+	}
+
+	notifier.Notify(definition)
+
+	return nil
+}
+
+// Errors are returned for the origins that are fetched again while keeping the secrets alive, so the renewer can retry
+// them instead of exiting
+func getSecretData(apiClient *api.Client, definition config.SecretDefinition, savedData *data.SavedData) (map[string]string, error) {
+	switch definition.Origin {
+	case constants.OriginVault, constants.OriginKv, constants.OriginVaultWrite:
+		return getSecretFromVault(apiClient, definition, savedData)
+	case constants.OriginFile:
+		return getSecretFromFile(definition), nil
+	case constants.OriginPki:
+		return getSecretFromPki(apiClient, definition, savedData)
+	case constants.OriginSsh:
+		return getSecretFromSsh(apiClient, definition, savedData)
+	case constants.OriginTransit:
+		return getSecretFromTransit(apiClient, definition), nil
+	case constants.OriginRandom:
+		return getSecretFromRandom(apiClient, definition, savedData), nil
+	case constants.OriginDirectory:
+		return getSecretFromDirectory(definition), nil
+	case constants.OriginEnv:
+		return getSecretFromEnv(definition), nil
+	case constants.OriginStatic:
+		return getSecretFromStatic(definition), nil
+	case constants.OriginToken:
+		return map[string]string{"token": apiClient.Token()}, nil
+	default:
+		panic("Invalid origin: " + definition.Origin)
+	}
+}
+
+func getSecretFromVault(apiClient *api.Client, definition config.SecretDefinition, savedData *data.SavedData) (map[string]string, error) {
+	secretData, response, err := readSecretFromVault(apiClient, definition)
+
+	if nil != err {
+		return nil, fmt.Errorf("failed to load secret %s from Vault: %w", definition.Source, err)
+	}
+
+	saveVaultSecret(definition, secretData, response, savedData)
+
+	return secretData, nil
+}
+
+func readSecretFromVault(apiClient *api.Client, definition config.SecretDefinition) (map[string]string, *api.Secret, error) {
+	if constants.OriginKv == definition.Origin {
+		return readSecretFromKv(apiClient, definition)
+	}
+
+	var response *api.Secret
+	var err error
+
+	if constants.OriginVaultWrite == definition.Origin {
+		response, err = apiClient.Logical().Write(definition.Source, getRequestParameters(definition))
+	} else {
+		response, err = apiClient.Logical().Read(definition.Source)
+	}
+
+	if nil != err {
+		return nil, nil, err
+	}
+
+	if nil == response {
+		return nil, nil, errors.New("secret " + definition.Source + " does not exist")
+	}
+
+	subKeyData, err := getDataForSubKey(response.Data, definition.SecretBaseKey)
 
 	if nil != err {
-		glog.Error("Failed to load secret "+defintion.Source+" from Vault: ", err)
+		return nil, nil, err
+	}
+
+	return stringifySecretData(subKeyData), response, nil
+}
+
+// YAML decodes nested objects with interface keys, which can't be encoded as JSON, so they are converted recursively
+func getRequestParameters(definition config.SecretDefinition) map[string]interface{} {
+	parameters := map[string]interface{}{}
+
+	for key, value := range definition.Parameters {
+		parameters[key] = normalizeYamlValue(value)
+	}
+
+	return parameters
+}
+
+func normalizeYamlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		normalized := map[string]interface{}{}
+
+		for key, item := range v {
+			normalized[fmt.Sprintf("%v", key)] = normalizeYamlValue(item)
+		}
+
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+
+		for i, item := range v {
+			normalized[i] = normalizeYamlValue(item)
+		}
+
+		return normalized
+	default:
+		return v
 	}
+}
 
+func stringifySecretData(sourceData map[string]interface{}) map[string]string {
 	secretData := map[string]string{}
 
-	for key, value := range getDataForSubKey(response.Data, defintion.SecretBaseKey) {
+	for key, value := range sourceData {
 		secretData[key] = fmt.Sprintf("%v", value)
 	}
 
+	return secretData
+}
+
+func saveVaultSecret(definition config.SecretDefinition, secretData map[string]string, response *api.Secret, savedData *data.SavedData) {
+	version := getKvVersion(response)
 	response.Data = nil
 
-	savedData.Secrets = append(savedData.Secrets, *response)
+	savedData.SetSecret(definition.Name, *response)
 
-	return secretData
+	savedSecret := savedData.GetSavedSecret(definition.Name)
+	savedSecret.ContentHash = getContentHash(secretData)
+	savedSecret.Version = version
+}
+
+// The KV version 2 engine returns the version of the secret in the metadata next to the data
+func getKvVersion(response *api.Secret) int {
+	metadata, ok := response.Data["metadata"].(map[string]interface{})
+
+	if !ok {
+		return 0
+	}
+
+	version, err := strconv.Atoi(fmt.Sprintf("%v", metadata["version"]))
+
+	if nil != err {
+		return 0
+	}
+
+	return version
+}
+
+func getContentHash(secretData map[string]string) string {
+	keys := make([]string, 0, len(secretData))
+
+	for key := range secretData {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	hash := sha256.New()
+
+	for _, key := range keys {
+		hash.Write([]byte(strconv.Quote(key) + "=" + strconv.Quote(secretData[key]) + "\n"))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
 }
 
-func getDataForSubKey(sourceData map[string]interface{}, key string) map[string]interface{} {
+func getDataForSubKey(sourceData map[string]interface{}, key string) (map[string]interface{}, error) {
 	if key == "" {
-		return sourceData
+		return sourceData, nil
 	}
 
 	subKeyData, ok := sourceData[key]
 
 	if !ok {
-		glog.Exit("Failed to get data from secret under base key " + key)
+		return nil, errors.New("failed to get data from secret under base key " + key)
 	}
 
 	switch v := subKeyData.(type) {
 	case map[string]interface{}:
-		return v
+		return v, nil
 	default:
-		glog.Exit("Invalid type for secret data")
+		return nil, errors.New("invalid type for secret data under base key " + key)
 	}
-
-	return nil
 }
 
 func getSecretFromFile(definition config.SecretDefinition) map[string]string {
@@ -106,7 +275,63 @@ func getSecretFromFile(definition config.SecretDefinition) map[string]string {
 		glog.Exit("Failed to read secret from source file: " + definition.Source)
 	}
 
+	if constants.SourceFormatRaw != definition.SourceFormat {
+		secretData, err := parseSourceFile(fileData, definition.SourceFormat)
+
+		if nil != err {
+			glog.Exit("Failed to parse source file "+definition.Source+" as "+definition.SourceFormat+": ", err)
+		}
+
+		return secretData
+	}
+
 	return map[string]string{
 		path.Base(definition.Source): string(fileData),
 	}
 }
+
+func getSecretFromEnv(definition config.SecretDefinition) map[string]string {
+	secretData := map[string]string{}
+
+	if "" != definition.Env.Prefix {
+		for _, variable := range os.Environ() {
+			parts := strings.SplitN(variable, "=", 2)
+
+			if !strings.HasPrefix(parts[0], definition.Env.Prefix) {
+				continue
+			}
+
+			key := parts[0]
+
+			if definition.Env.StripPrefix {
+				key = strings.TrimPrefix(key, definition.Env.Prefix)
+			}
+
+			if "" != key {
+				secretData[key] = parts[1]
+			}
+		}
+	}
+
+	for _, name := range definition.Env.Variables {
+		value, ok := os.LookupEnv(name)
+
+		if !ok {
+			glog.Exit("Environment variable " + name + " is not set for secret " + definition.Name)
+		}
+
+		secretData[name] = value
+	}
+
+	return secretData
+}
+
+func getSecretFromStatic(definition config.SecretDefinition) map[string]string {
+	secretData := map[string]string{}
+
+	for key, value := range definition.Values {
+		secretData[key] = value
+	}
+
+	return secretData
+}
@@ -0,0 +1,178 @@
+package secret_manager
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/hashicorp/vault/api"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/data"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/vault"
+	"math/big"
+	"strings"
+)
+
+const randomWriteAttempts = 3
+
+func getSecretFromRandom(apiClient *api.Client, definition config.SecretDefinition, savedData *data.SavedData) map[string]string {
+	secretData, response, err := readOrGenerateRandomSecret(apiClient, definition)
+
+	if nil != err {
+		glog.Exit("Failed to load or generate random secret "+definition.Source+" in Vault: ", err)
+	}
+
+	saveVaultSecret(definition, secretData, response, savedData)
+
+	return secretData
+}
+
+// The missing keys are generated and written with check-and-set, so if multiple managers generate the values at the
+// same time, only one write succeeds and the others read back the written values on the next attempt. KV version 1
+// mounts don't support check-and-set, so the last write wins there. Any other write error is returned immediately.
+func readOrGenerateRandomSecret(apiClient *api.Client, definition config.SecretDefinition) (map[string]string, *api.Secret, error) {
+	mount, err := getKvMount(apiClient, definition.Source)
+
+	if nil != err {
+		return nil, nil, err
+	}
+
+	var lastWriteErr error
+
+	for attempt := 1; ; attempt++ {
+		sourceData, response, err := readKvDataIfExists(apiClient, mount, definition.Source)
+
+		if nil != err {
+			return nil, nil, err
+		}
+
+		missingKeys := getMissingKeys(sourceData, definition.Random.Keys)
+
+		if len(missingKeys) == 0 {
+			return stringifySecretData(sourceData), response, nil
+		}
+
+		if attempt > randomWriteAttempts {
+			if nil == lastWriteErr {
+				return nil, nil, errors.New("failed to write the generated values")
+			}
+
+			return nil, nil, fmt.Errorf("failed to write the generated values: %w", lastWriteErr)
+		}
+
+		for _, key := range missingKeys {
+			value, err := generateRandomValue(*definition.Random)
+
+			if nil != err {
+				return nil, nil, err
+			}
+
+			sourceData[key] = value
+		}
+
+		glog.Infof("Writing generated values for %v to %s", missingKeys, definition.Source)
+		err = writeKvData(apiClient, mount, definition.Source, sourceData, getKvVersion(response))
+
+		if nil != err {
+			if !isCheckAndSetMismatch(err) {
+				return nil, nil, fmt.Errorf("failed to write the generated values: %w", err)
+			}
+
+			glog.Warningf("The secret %s was written by someone else, attempt %d: %v", definition.Source, attempt, err)
+			lastWriteErr = err
+		}
+	}
+}
+
+// A missing or deleted secret is returned as an empty secret, so the values can be generated for it
+func readKvDataIfExists(apiClient *api.Client, mount vault.KvMount, secretPath string) (map[string]interface{}, *api.Secret, error) {
+	dataPath := getKvPath(mount, secretPath, "data/")
+	glog.V(1).Info("Reading KV secret from " + dataPath)
+	response, err := apiClient.Logical().Read(dataPath)
+
+	if nil != err {
+		return nil, nil, err
+	}
+
+	if nil == response {
+		return map[string]interface{}{}, &api.Secret{}, nil
+	}
+
+	if nil == response.Data {
+		response.Data = map[string]interface{}{}
+	}
+
+	if mount.Version == 1 {
+		return response.Data, response, nil
+	}
+
+	sourceData, ok := response.Data["data"].(map[string]interface{})
+
+	if !ok {
+		sourceData = map[string]interface{}{}
+	}
+
+	return sourceData, response, nil
+}
+
+func writeKvData(apiClient *api.Client, mount vault.KvMount, secretPath string, sourceData map[string]interface{}, version int) error {
+	dataPath := getKvPath(mount, secretPath, "data/")
+	body := sourceData
+
+	if mount.Version == 2 {
+		body = map[string]interface{}{
+			"data":    sourceData,
+			"options": map[string]interface{}{"cas": version},
+		}
+	}
+
+	_, err := apiClient.Logical().Write(dataPath, body)
+
+	return err
+}
+
+func isCheckAndSetMismatch(err error) bool {
+	return strings.Contains(err.Error(), "check-and-set parameter did not match")
+}
+
+func getMissingKeys(sourceData map[string]interface{}, keys []string) []string {
+	var missingKeys []string
+
+	for _, key := range keys {
+		if value, ok := sourceData[key]; !ok || "" == fmt.Sprintf("%v", value) {
+			missingKeys = append(missingKeys, key)
+		}
+	}
+
+	return missingKeys
+}
+
+func generateRandomValue(definition config.RandomDefinition) (string, error) {
+	if definition.Bytes > 0 {
+		value := make([]byte, definition.Bytes)
+
+		if _, err := rand.Read(value); nil != err {
+			return "", err
+		}
+
+		return base64.StdEncoding.EncodeToString(value), nil
+	}
+
+	charset := constants.RandomCharsets[definition.Charset]
+	charsetLength := big.NewInt(int64(len(charset)))
+	value := make([]byte, definition.Length)
+
+	for i := range value {
+		index, err := rand.Int(rand.Reader, charsetLength)
+
+		if nil != err {
+			return "", err
+		}
+
+		value[i] = charset[index.Int64()]
+	}
+
+	return string(value), nil
+}
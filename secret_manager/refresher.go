@@ -0,0 +1,71 @@
+package secret_manager
+
+import (
+	"github.com/golang/glog"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/data"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/formatter"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/notifier"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/vault"
+	"time"
+)
+
+func refreshSecretIfChanged(savedData *data.SavedData, appConfig config.Config, queue *renewalQueue, name string) error {
+	definition, ok := appConfig.GetSecretDefinition(name)
+
+	if !ok || definition.RefreshInterval <= 0 {
+		queue.remove(queueItemRefresh, name)
+		return nil
+	}
+
+	glog.V(1).Info("Checking secret " + name + " for changes")
+
+	apiClient, err := getClient(appConfig, savedData.LoginToken)
+
+	if nil != err {
+		return err
+	}
+
+	secretData, response, err := readSecretFromVault(apiClient, definition)
+
+	if nil != err {
+		glog.Error("Failed to load secret "+definition.Source+" from Vault: ", err)
+		return err
+	}
+
+	// Reading a dynamic secret creates new credentials every time, so the new lease is revoked straight away, and the
+	// secret is no longer refreshed
+	if "" != response.LeaseID {
+		glog.Warning("Secret " + name + " has a lease, it is not refreshed any more. Remove its refreshInterval")
+		queue.remove(queueItemRefresh, name)
+
+		return vault.RevokeLease(apiClient, response.LeaseID)
+	}
+
+	savedSecret := savedData.GetSavedSecret(name)
+
+	if nil != savedSecret &&
+		savedSecret.ContentHash == getContentHash(secretData) &&
+		savedSecret.Version == getKvVersion(response) {
+		glog.V(1).Info("Secret " + name + " has not changed")
+		savedSecret.NextRefreshTimestamp = int(time.Now().UTC().Unix()) + definition.RefreshInterval
+		scheduleSecretRefresh(queue, savedData, definition)
+
+		return nil
+	}
+
+	glog.Info("Secret " + name + " has changed, writing it again")
+
+	saveVaultSecret(definition, secretData, response, savedData)
+
+	if err := formatter.FormatSecret(secretData, definition, savedData); nil != err {
+		return err
+	}
+
+	notifier.Notify(definition)
+
+	scheduleSecretRenewal(queue, savedData, name)
+	scheduleSecretRefresh(queue, savedData, definition)
+
+	return nil
+}
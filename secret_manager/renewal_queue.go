@@ -0,0 +1,234 @@
+package secret_manager
+
+import (
+	"container/heap"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/data"
+	"math/rand"
+)
+
+const (
+	queueItemAuthToken = iota
+	queueItemLease
+	queueItemRefresh
+	queueItemReissue
+	queueItemRefetch
+)
+
+type renewalQueueItem struct {
+	kind                int
+	secretName          string
+	renewalTimestamp    int
+	expirationTimestamp int
+	index               int
+}
+
+// renewalQueue is a min-heap of the auth token, the renewable secret leases and the secrets to refresh ordered by the
+// time they should be processed next
+type renewalQueue []*renewalQueueItem
+
+func (q renewalQueue) Len() int {
+	return len(q)
+}
+
+func (q renewalQueue) Less(i, j int) bool {
+	return q[i].renewalTimestamp < q[j].renewalTimestamp
+}
+
+func (q renewalQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *renewalQueue) Push(x interface{}) {
+	item := x.(*renewalQueueItem)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *renewalQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*q = old[:n-1]
+
+	return item
+}
+
+func newRenewalQueue(savedData *data.SavedData, appConfig config.Config) *renewalQueue {
+	queue := &renewalQueue{}
+	queue.rebuild(savedData, appConfig)
+
+	return queue
+}
+
+func (q *renewalQueue) rebuild(savedData *data.SavedData, appConfig config.Config) {
+	*q = renewalQueue{}
+
+	scheduleAuthTokenRenewal(q, savedData)
+
+	for _, savedSecret := range savedData.Secrets {
+		scheduleSecretRenewal(q, savedData, savedSecret.Name)
+		scheduleSecretReissue(q, savedData, savedSecret.Name)
+	}
+
+	for _, definition := range appConfig.Secrets {
+		scheduleSecretRefresh(q, savedData, definition)
+	}
+}
+
+func (q *renewalQueue) peek() *renewalQueueItem {
+	if 0 == q.Len() {
+		return nil
+	}
+
+	return (*q)[0]
+}
+
+func (q *renewalQueue) find(kind int, secretName string) *renewalQueueItem {
+	for _, item := range *q {
+		if item.kind == kind && item.secretName == secretName {
+			return item
+		}
+	}
+
+	return nil
+}
+
+func (q *renewalQueue) set(kind int, secretName string, renewalTimestamp int, expirationTimestamp int) {
+	item := q.find(kind, secretName)
+
+	if nil == item {
+		heap.Push(q, &renewalQueueItem{
+			kind:                kind,
+			secretName:          secretName,
+			renewalTimestamp:    renewalTimestamp,
+			expirationTimestamp: expirationTimestamp,
+		})
+		return
+	}
+
+	item.renewalTimestamp = renewalTimestamp
+	item.expirationTimestamp = expirationTimestamp
+	heap.Fix(q, item.index)
+}
+
+func (q *renewalQueue) remove(kind int, secretName string) {
+	item := q.find(kind, secretName)
+
+	if nil != item {
+		heap.Remove(q, item.index)
+	}
+}
+
+func (q *renewalQueue) postpone(item *renewalQueueItem, renewalTimestamp int) {
+	item.renewalTimestamp = renewalTimestamp
+	heap.Fix(q, item.index)
+}
+
+func scheduleAuthTokenRenewal(queue *renewalQueue, savedData *data.SavedData) {
+	if savedData.AuthLeaseDuration <= 0 {
+		queue.remove(queueItemAuthToken, "")
+		return
+	}
+
+	if 0 == savedData.NextAuthRenewalTimestamp {
+		savedData.NextAuthRenewalTimestamp = getNextRenewalTimestamp(savedData.CreationTimestamp, savedData.AuthLeaseDuration)
+	}
+
+	queue.set(queueItemAuthToken, "", savedData.NextAuthRenewalTimestamp, savedData.GetAuthExpirationTimestamp())
+}
+
+func scheduleSecretRenewal(queue *renewalQueue, savedData *data.SavedData, name string) {
+	savedSecret := savedData.GetSavedSecret(name)
+
+	if nil == savedSecret || !savedSecret.Renewable || savedSecret.LeaseDuration <= 0 {
+		queue.remove(queueItemLease, name)
+		return
+	}
+
+	if 0 == savedSecret.NextRenewalTimestamp {
+		savedSecret.NextRenewalTimestamp = getNextRenewalTimestamp(savedSecret.LeaseTimestamp, savedSecret.LeaseDuration)
+	}
+
+	queue.set(queueItemLease, name, savedSecret.NextRenewalTimestamp, savedSecret.GetExpirationTimestamp())
+}
+
+// Refreshed secrets are never given up on, so they don't have an expiration timestamp. Secrets with a lease are dynamic,
+// so they are not refreshed, as every read would create new credentials.
+func scheduleSecretRefresh(queue *renewalQueue, savedData *data.SavedData, definition config.SecretDefinition) {
+	savedSecret := savedData.GetSavedSecret(definition.Name)
+
+	if nil == savedSecret || definition.RefreshInterval <= 0 || "" != savedSecret.LeaseID {
+		queue.remove(queueItemRefresh, definition.Name)
+		return
+	}
+
+	if 0 == savedSecret.NextRefreshTimestamp {
+		savedSecret.NextRefreshTimestamp = savedSecret.LeaseTimestamp + definition.RefreshInterval
+	}
+
+	queue.set(queueItemRefresh, definition.Name, savedSecret.NextRefreshTimestamp, 0)
+}
+
+// Secrets without a renewable lease but with an expiration (like certificates, or credentials with a non-renewable
+// lease) are issued again after the same fraction of their lifetime as leases are renewed
+func scheduleSecretReissue(queue *renewalQueue, savedData *data.SavedData, name string) {
+	savedSecret := savedData.GetSavedSecret(name)
+
+	if nil == savedSecret {
+		queue.remove(queueItemReissue, name)
+		return
+	}
+
+	expirationTimestamp := savedSecret.ExpirationTimestamp
+
+	if !savedSecret.Renewable && "" != savedSecret.LeaseID && savedSecret.LeaseDuration > 0 {
+		expirationTimestamp = savedSecret.GetExpirationTimestamp()
+	}
+
+	if expirationTimestamp <= savedSecret.LeaseTimestamp {
+		queue.remove(queueItemReissue, name)
+		return
+	}
+
+	if 0 == savedSecret.NextReissueTimestamp {
+		savedSecret.NextReissueTimestamp = getNextRenewalTimestamp(
+			savedSecret.LeaseTimestamp,
+			expirationTimestamp-savedSecret.LeaseTimestamp,
+		)
+	}
+
+	queue.set(queueItemReissue, name, savedSecret.NextReissueTimestamp, expirationTimestamp)
+}
+
+// Secrets that could not be fetched again after logging in are retried until their old lease expires. Their lease is
+// not renewed or reissued in the meantime.
+func scheduleSecretRefetch(queue *renewalQueue, savedData *data.SavedData, name string, timestamp int) {
+	expirationTimestamp := 0
+
+	if savedSecret := savedData.GetSavedSecret(name); nil != savedSecret {
+		expirationTimestamp = savedSecret.GetExpirationTimestamp()
+	}
+
+	queue.remove(queueItemLease, name)
+	queue.remove(queueItemReissue, name)
+	queue.set(queueItemRefetch, name, timestamp, expirationTimestamp)
+}
+
+// The renewal is scheduled after a fraction of the lease duration, brought forward by a random jitter, so that pods
+// started at the same time don't all renew their leases at the same time
+func getNextRenewalTimestamp(leaseTimestamp int, leaseDuration int) int {
+	interval := leaseDuration / constants.LifetimeDivisor
+	jitter := 0
+
+	if maxJitter := interval / constants.RenewalJitterDivisor; maxJitter > 0 {
+		jitter = rand.Intn(maxJitter + 1)
+	}
+
+	return leaseTimestamp + interval - jitter
+}
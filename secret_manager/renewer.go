@@ -2,28 +2,58 @@ package secret_manager
 
 import (
 	"github.com/golang/glog"
+	"github.com/hashicorp/vault/api"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/data"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/notifier"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/vault"
 	"time"
 )
 
 func KeepSecretsAlive(appConfig config.Config, httpPort int) {
+	glog.Exit("Failed to keep the secrets alive, giving up: ", keepSecretsAlive(appConfig, httpPort))
+}
+
+// keepSecretsAlive processes the renewal queue until an item fails to be processed before its expiration, or the data
+// file can't be loaded or saved, and returns the error, so exec mode can stop the command before exiting
+func keepSecretsAlive(appConfig config.Config, httpPort int) error {
 	serverHttpPort = httpPort
-	startHttpServer()
-	savedData := data.Load(appConfig.DataDir)
+
+	if err := startHttpServer(); nil != err {
+		return err
+	}
+
+	savedData, err := data.Load(appConfig.DataDir)
+
+	if nil != err {
+		return err
+	}
+
+	queue := newRenewalQueue(&savedData, appConfig)
+	notifier.SetDebounceSeconds(*appConfig.NotifyDebounce)
 
 	for {
-		runRenewal(appConfig, &savedData)
+		if err := runRenewal(appConfig, &savedData, queue); nil != err {
+			return err
+		}
 	}
 }
 
-func runRenewal(appConfig config.Config, savedData *data.SavedData) {
-	getClient(appConfig, savedData.LoginToken)
-	nextProcessingTimestamp := getNextSecretToRenew(savedData)
+func runRenewal(appConfig config.Config, savedData *data.SavedData, queue *renewalQueue) error {
+	if _, err := getClient(appConfig, savedData.LoginToken); nil != err {
+		return err
+	}
+
+	item := queue.peek()
 
-	nextProcessingTime := time.Unix(nextProcessingTimestamp, 0)
+	if nil == item {
+		isAlive = true
+		glog.Info("There are no leases to renew")
+		select {}
+	}
+
+	nextProcessingTime := time.Unix(int64(item.renewalTimestamp), 0)
 
 	if nextProcessingTime.After(time.Now()) {
 		isAlive = true
@@ -31,59 +61,271 @@ func runRenewal(appConfig config.Config, savedData *data.SavedData) {
 		time.Sleep(nextProcessingTime.Sub(time.Now()))
 	}
 
-	if err := renewSecrets(savedData, appConfig); err != nil {
-		if time.Now().After(savedData.GetTimeOfShortestExpiration().Add(-5 * time.Second)) {
-			glog.Exit("Failed to renew the secrets, giving up: ", err)
-		} else {
-			glog.Warning("Error while renewing secrets. Sleeping for 5 seconds before trying again")
-			time.Sleep(5 * time.Second)
+	var err error
+
+	switch item.kind {
+	case queueItemAuthToken:
+		err = renewAuthToken(savedData, appConfig, queue)
+	case queueItemLease:
+		err = renewSecretLease(savedData, appConfig, queue, item.secretName)
+	case queueItemRefresh:
+		err = refreshSecretIfChanged(savedData, appConfig, queue, item.secretName)
+	case queueItemReissue:
+		err = reissueSecret(savedData, appConfig, queue, item.secretName)
+	case queueItemRefetch:
+		err = refetchSecret(savedData, appConfig, queue, item.secretName)
+	}
+
+	if err != nil {
+		if 0 != item.expirationTimestamp && time.Now().After(time.Unix(int64(item.expirationTimestamp), 0).Add(-5*time.Second)) {
+			return err
 		}
+
+		glog.Warning("Error while renewing or refreshing the secrets, trying again in 5 seconds: ", err)
+		queue.postpone(item, int(time.Now().Add(5*time.Second).UTC().Unix()))
 	}
 
-	data.Save(appConfig.DataDir, *savedData)
+	return data.Save(appConfig.DataDir, *savedData)
 }
 
-func getNextSecretToRenew(savedData *data.SavedData) int64 {
-	return int64(savedData.CreationTimestamp + (savedData.GetShortestExpirationSeconds() / constants.LifetimeDivisor))
-}
+func renewAuthToken(savedData *data.SavedData, appConfig config.Config, queue *renewalQueue) error {
+	glog.Info("Renewing the auth token lease")
 
-func renewSecrets(savedData *data.SavedData, appConfig config.Config) error {
-	glog.Info("Starting lease renewals")
+	apiClient, err := getClient(appConfig, savedData.LoginToken)
+
+	if nil != err {
+		return err
+	}
 
-	apiClient := getClient(appConfig, savedData.LoginToken)
 	newCreationTimestamp := int(time.Now().UTC().Unix())
-	apiClientAuthLifetimeSeconds, err := vault.RenewTokenLease(apiClient)
+	authLeaseDuration, err := vault.RenewTokenLease(apiClient)
+
+	if nil != err {
+		glog.Warning("Failed to renew the auth token lease: ", err)
+		// The renewal may have failed for a transient reason while the old token, and its leases, are still alive
+		return reloginAndRefreshSecrets(savedData, appConfig, queue, !vault.IsTokenRevoked(apiClient))
+	}
+
+	if authLeaseDuration < savedData.AuthLeaseDuration {
+		glog.Infof("The auth token lease was only renewed for %d seconds, it is reaching its max TTL", authLeaseDuration)
+		return reloginAndRefreshSecrets(savedData, appConfig, queue, true)
+	}
+
+	setApiClientAuthLifetime(authLeaseDuration)
+	savedData.AuthLeaseDuration = authLeaseDuration
+	savedData.CreationTimestamp = newCreationTimestamp
+	savedData.NextAuthRenewalTimestamp = 0
+	scheduleAuthTokenRenewal(queue, savedData)
+
+	glog.Info("Renewed auth token lease. New expiration: ", apiClientAuthLifetime)
+
+	return nil
+}
+
+func renewSecretLease(savedData *data.SavedData, appConfig config.Config, queue *renewalQueue, name string) error {
+	apiClient, err := getClient(appConfig, savedData.LoginToken)
+
+	if nil != err {
+		return err
+	}
+
+	secretData, ok := savedData.GetSecret(name)
+
+	if !ok {
+		queue.remove(queueItemLease, name)
+		return nil
+	}
+
+	glog.V(1).Info("Renewing lease " + secretData.LeaseID)
+	newSecret, err := vault.RenewLease(apiClient, secretData)
+
+	// The lease may have been revoked together with the token that created it, so the secret is fetched again
+	if nil != err {
+		glog.Warning("Failed to renew the lease for secret "+name+", fetching it again: ", err)
+		scheduleSecretRefetch(queue, savedData, name, int(time.Now().UTC().Unix()))
+		return nil
+	}
+
+	savedData.SetSecret(name, *newSecret)
+
+	glog.Info(
+		"Renewed lease for secret "+name+". New validity: ",
+		time.Now().Add(time.Second*time.Duration(int64(newSecret.LeaseDuration))),
+	)
+
+	if _, err := refreshSecretIfLeaseIsCapped(apiClient, appConfig, savedData, secretData, *newSecret, name); nil != err {
+		return err
+	}
+
+	scheduleSecretRenewal(queue, savedData, name)
+
+	return nil
+}
+
+// Leases are revoked together with the token that created them. If the old token is still alive, it will expire
+// shortly, so every leased secret is fetched again. Otherwise the leases are renewed with the new token, and only the
+// ones Vault refuses to renew are fetched again. Secrets that fail to be fetched are retried from the queue.
+func reloginAndRefreshSecrets(savedData *data.SavedData, appConfig config.Config, queue *renewalQueue, isOldTokenAlive bool) error {
+	apiClient, err := relogin(appConfig, savedData)
 
 	if nil != err {
 		return err
 	}
 
-	apiClientAuthLifetime = time.Now().Add(time.Second * time.Duration(apiClientAuthLifetimeSeconds))
 	renewedSecretCount := 0
+	refreshedSecretCount := 0
+	var failedSecretNames []string
 
-	glog.Info("Renewed auth token lease. New expiration: ", apiClientAuthLifetime)
+	for _, definition := range appConfig.Secrets {
+		if constants.OriginToken == definition.Origin {
+			glog.Info("Writing the new token for secret " + definition.Name)
+
+			if err := refreshSecret(apiClient, definition, savedData); nil != err {
+				glog.Warning("Failed to write the new token for secret "+definition.Name+": ", err)
+				failedSecretNames = append(failedSecretNames, definition.Name)
+				continue
+			}
 
-	for key, secretData := range savedData.Secrets {
-		if secretData.Renewable {
-			glog.V(1).Info("Renewing lease " + secretData.LeaseID)
-			newSecret, err := vault.RenewLease(apiClient, secretData)
+			refreshedSecretCount = refreshedSecretCount + 1
+			continue
+		}
+
+		savedSecret, ok := savedData.GetSecret(definition.Name)
+
+		if !ok || "" == savedSecret.LeaseID {
+			continue
+		}
 
-			if nil != err {
-				return err
+		if savedSecret.Renewable && !isOldTokenAlive {
+			glog.V(1).Info("Renewing lease " + savedSecret.LeaseID)
+			newSecret, err := vault.RenewLease(apiClient, savedSecret)
+
+			if nil == err {
+				savedData.SetSecret(definition.Name, *newSecret)
+				renewedSecretCount = renewedSecretCount + 1
+
+				isRefreshed, err := refreshSecretIfLeaseIsCapped(apiClient, appConfig, savedData, savedSecret, *newSecret, definition.Name)
+
+				if nil != err {
+					glog.Warning("Failed to fetch secret "+definition.Name+" again: ", err)
+					failedSecretNames = append(failedSecretNames, definition.Name)
+				} else if isRefreshed {
+					refreshedSecretCount = refreshedSecretCount + 1
+				}
+
+				continue
 			}
 
-			savedData.Secrets[key] = *newSecret
+			glog.Warning("Failed to renew the lease for secret " + definition.Name + ", fetching it again")
+		}
+
+		glog.Info("Fetching secret " + definition.Name + " again")
 
-			glog.V(1).Info(
-				"Lease renewed. New validity: ",
-				time.Now().Add(time.Second*time.Duration(int64(savedData.Secrets[key].LeaseDuration))),
-			)
-			renewedSecretCount = renewedSecretCount + 1
+		if err := refreshSecret(apiClient, definition, savedData); nil != err {
+			glog.Warning("Failed to fetch secret "+definition.Name+" again: ", err)
+			failedSecretNames = append(failedSecretNames, definition.Name)
+			continue
 		}
+
+		refreshedSecretCount = refreshedSecretCount + 1
 	}
 
-	savedData.CreationTimestamp = newCreationTimestamp
-	glog.Infof("Renewed %d secret leases", renewedSecretCount)
+	queue.rebuild(savedData, appConfig)
+
+	for _, name := range failedSecretNames {
+		scheduleSecretRefetch(queue, savedData, name, int(time.Now().Add(5*time.Second).UTC().Unix()))
+	}
+
+	glog.Infof("Renewed %d secret leases and refreshed %d secrets after logging in again", renewedSecretCount, refreshedSecretCount)
+
+	return nil
+}
+
+// Vault caps the renewed lease duration at the max TTL of the lease, so a renewal returning a shorter duration than
+// requested, or one not outlasting the renewal interval, means the lease will end soon. The secret is fetched again
+// and its destination rewritten before that.
+func refreshSecretIfLeaseIsCapped(
+	apiClient *api.Client,
+	appConfig config.Config,
+	savedData *data.SavedData,
+	oldSecret api.Secret,
+	newSecret api.Secret,
+	name string,
+) (bool, error) {
+	renewalInterval := oldSecret.LeaseDuration / constants.LifetimeDivisor
+
+	if newSecret.LeaseDuration >= oldSecret.LeaseDuration && newSecret.LeaseDuration > renewalInterval {
+		return false, nil
+	}
+
+	definition, ok := appConfig.GetSecretDefinition(name)
+
+	if !ok {
+		glog.Warningf("The lease %s is reaching its max TTL, but it doesn't belong to a known secret", oldSecret.LeaseID)
+		return false, nil
+	}
+
+	glog.Infof(
+		"The lease for secret %s was renewed for %d seconds instead of %d, it is reaching its max TTL. Fetching it again",
+		name,
+		newSecret.LeaseDuration,
+		oldSecret.LeaseDuration,
+	)
+
+	if err := refreshSecret(apiClient, definition, savedData); nil != err {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func reissueSecret(savedData *data.SavedData, appConfig config.Config, queue *renewalQueue, name string) error {
+	definition, ok := appConfig.GetSecretDefinition(name)
+
+	if !ok {
+		queue.remove(queueItemReissue, name)
+		return nil
+	}
+
+	glog.Info("Secret " + name + " is approaching its expiration, issuing it again")
+	apiClient, err := getClient(appConfig, savedData.LoginToken)
+
+	if nil != err {
+		return err
+	}
+
+	if err := refreshSecret(apiClient, definition, savedData); nil != err {
+		return err
+	}
+
+	scheduleSecretRenewal(queue, savedData, name)
+	scheduleSecretReissue(queue, savedData, name)
+
+	return nil
+}
+
+func refetchSecret(savedData *data.SavedData, appConfig config.Config, queue *renewalQueue, name string) error {
+	definition, ok := appConfig.GetSecretDefinition(name)
+
+	if !ok {
+		queue.remove(queueItemRefetch, name)
+		return nil
+	}
+
+	glog.Info("Fetching secret " + name + " again")
+	apiClient, err := getClient(appConfig, savedData.LoginToken)
+
+	if nil != err {
+		return err
+	}
+
+	if err := refreshSecret(apiClient, definition, savedData); nil != err {
+		return err
+	}
+
+	queue.remove(queueItemRefetch, name)
+	scheduleSecretRenewal(queue, savedData, name)
+	scheduleSecretReissue(queue, savedData, name)
 
 	return nil
 }
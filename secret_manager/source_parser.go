@@ -0,0 +1,310 @@
+package secret_manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+	"gopkg.in/yaml.v3"
+	"strconv"
+	"strings"
+)
+
+func parseSourceFile(fileData []byte, sourceFormat string) (map[string]string, error) {
+	switch sourceFormat {
+	case constants.SourceFormatDotenv:
+		return parseDotenv(string(fileData))
+	case constants.SourceFormatJson:
+		var sourceData map[string]interface{}
+		// Numbers are kept in their original form instead of being converted to floats
+		decoder := json.NewDecoder(bytes.NewReader(fileData))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&sourceData); nil != err {
+			return nil, err
+		}
+
+		return stringifySourceData(sourceData)
+	case constants.SourceFormatYaml:
+		// The document is read as nodes, so the scalars keep their original text instead of being resolved, like 01234
+		// to an octal number or 1.10 to 1.1
+		var document yaml.Node
+
+		if err := yaml.Unmarshal(fileData, &document); nil != err {
+			return nil, err
+		}
+
+		sourceData, err := getYamlSourceData(&document)
+
+		if nil != err {
+			return nil, err
+		}
+
+		return stringifySourceData(sourceData)
+	default:
+		return nil, errors.New("invalid source format: " + sourceFormat)
+	}
+}
+
+// Scalar values are used as they are, while nested objects and arrays are encoded as JSON
+func stringifySourceData(sourceData map[string]interface{}) (map[string]string, error) {
+	secretData := map[string]string{}
+
+	for key, value := range sourceData {
+		switch v := normalizeYamlValue(value).(type) {
+		case nil:
+			secretData[key] = ""
+		case json.Number:
+			secretData[key] = v.String()
+		case map[string]interface{}, []interface{}:
+			encoded, err := json.Marshal(v)
+
+			if nil != err {
+				return nil, err
+			}
+
+			secretData[key] = string(encoded)
+		default:
+			secretData[key] = fmt.Sprintf("%v", v)
+		}
+	}
+
+	return secretData, nil
+}
+
+func getYamlSourceData(document *yaml.Node) (map[string]interface{}, error) {
+	sourceData := map[string]interface{}{}
+
+	if 0 == len(document.Content) {
+		return sourceData, nil
+	}
+
+	root := document.Content[0]
+
+	if yaml.MappingNode != root.Kind {
+		return nil, errors.New("the YAML document is not a mapping")
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		valueNode := root.Content[i+1]
+
+		if yaml.AliasNode == valueNode.Kind {
+			valueNode = valueNode.Alias
+		}
+
+		if yaml.ScalarNode == valueNode.Kind && "!!null" != valueNode.ShortTag() {
+			sourceData[root.Content[i].Value] = valueNode.Value
+			continue
+		}
+
+		sourceData[root.Content[i].Value] = getYamlNodeValue(valueNode)
+	}
+
+	return sourceData, nil
+}
+
+// Nested values are encoded as JSON, so numbers keep their original text if it's a valid JSON number, and are strings
+// otherwise
+func getYamlNodeValue(node *yaml.Node) interface{} {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return getYamlNodeValue(node.Alias)
+	case yaml.MappingNode:
+		value := map[string]interface{}{}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value[node.Content[i].Value] = getYamlNodeValue(node.Content[i+1])
+		}
+
+		return value
+	case yaml.SequenceNode:
+		value := []interface{}{}
+
+		for _, item := range node.Content {
+			value = append(value, getYamlNodeValue(item))
+		}
+
+		return value
+	}
+
+	switch node.ShortTag() {
+	case "!!null":
+		return nil
+	case "!!bool":
+		var value bool
+
+		if nil == node.Decode(&value) {
+			return value
+		}
+	case "!!int", "!!float":
+		if json.Valid([]byte(node.Value)) {
+			return json.Number(node.Value)
+		}
+	}
+
+	return node.Value
+}
+
+// Supports the commonly used subset of the dotenv syntax: comments, optional export prefixes, unquoted values with
+// trailing comments, literal single quoted values and double quoted values with escape sequences. Quoted values may
+// span multiple lines, and adjacent quoted parts are joined like in a shell, so the output of every dotenv dialect can
+// be read back.
+func parseDotenv(content string) (map[string]string, error) {
+	secretData := map[string]string{}
+	lineNumber := 1
+
+	for position := 0; position < len(content); lineNumber++ {
+		lineEnd := strings.IndexByte(content[position:], '\n')
+
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += position
+		}
+
+		line := content[position:lineEnd]
+		trimmedLine := strings.TrimSpace(line)
+
+		if "" == trimmedLine || strings.HasPrefix(trimmedLine, "#") {
+			position = lineEnd + 1
+			continue
+		}
+
+		separator := strings.IndexByte(line, '=')
+
+		if separator < 0 {
+			return nil, fmt.Errorf("invalid line %d: no = found", lineNumber)
+		}
+
+		key := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[:separator]), "export "))
+
+		if "" == key {
+			return nil, fmt.Errorf("invalid line %d: empty key", lineNumber)
+		}
+
+		valueStart := position + separator + 1
+
+		for valueStart < lineEnd && (' ' == content[valueStart] || '\t' == content[valueStart]) {
+			valueStart++
+		}
+
+		if valueStart == lineEnd || ('"' != content[valueStart] && '\'' != content[valueStart]) {
+			secretData[key] = parseUnquotedDotenvValue(content[valueStart:lineEnd])
+			position = lineEnd + 1
+			continue
+		}
+
+		value, valueEnd, err := parseQuotedDotenvValue(content, valueStart)
+
+		if nil != err {
+			return nil, fmt.Errorf("invalid value on line %d: %s", lineNumber, err)
+		}
+
+		lineNumber += strings.Count(content[valueStart:valueEnd], "\n")
+		lineEnd = strings.IndexByte(content[valueEnd:], '\n')
+
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += valueEnd
+		}
+
+		if rest := strings.TrimSpace(content[valueEnd:lineEnd]); "" != rest && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("invalid value on line %d: unexpected characters after the quoted value", lineNumber)
+		}
+
+		secretData[key] = value
+		position = lineEnd + 1
+	}
+
+	return secretData, nil
+}
+
+func parseUnquotedDotenvValue(value string) string {
+	if commentStart := strings.Index(value, " #"); commentStart >= 0 {
+		value = value[:commentStart]
+	}
+
+	return strings.TrimSpace(value)
+}
+
+// Parses the quoted parts of the value starting at the start position, and returns the value and the position after it
+func parseQuotedDotenvValue(content string, start int) (string, int, error) {
+	var value strings.Builder
+	position := start
+
+	for position < len(content) {
+		switch content[position] {
+		case '\'':
+			end := strings.IndexByte(content[position+1:], '\'')
+
+			if end < 0 {
+				return "", 0, errors.New("unterminated single quoted value")
+			}
+
+			value.WriteString(content[position+1 : position+1+end])
+			position += end + 2
+		case '"':
+			end, err := unquoteDoubleQuotedDotenvValue(content, position, &value)
+
+			if nil != err {
+				return "", 0, err
+			}
+
+			position = end
+		case '\\':
+			// An escaped character between the quoted parts, like the single quotes written as '\''
+			if position+1 >= len(content) || '\n' == content[position+1] {
+				return value.String(), position, nil
+			}
+
+			value.WriteByte(content[position+1])
+			position += 2
+		default:
+			return value.String(), position, nil
+		}
+	}
+
+	return value.String(), position, nil
+}
+
+// Unescapes the Go escape sequences, and the \$ and \` escapes used by systemd and phpdotenv. Unknown escape sequences
+// are kept as they are.
+func unquoteDoubleQuotedDotenvValue(content string, start int, value *strings.Builder) (int, error) {
+	position := start + 1
+
+	for position < len(content) {
+		character := content[position]
+
+		switch {
+		case '"' == character:
+			return position + 1, nil
+		case '\\' != character || position+1 >= len(content):
+			value.WriteByte(character)
+			position++
+		case '$' == content[position+1] || '`' == content[position+1]:
+			value.WriteByte(content[position+1])
+			position += 2
+		default:
+			decoded, isMultiByte, tail, err := strconv.UnquoteChar(content[position:], '"')
+
+			if nil != err {
+				value.WriteByte(character)
+				position++
+				continue
+			}
+
+			// \x and octal escapes are single bytes, which may not be valid UTF-8 on their own
+			if isMultiByte {
+				value.WriteRune(decoded)
+			} else {
+				value.WriteByte(byte(decoded))
+			}
+
+			position = len(content) - len(tail)
+		}
+	}
+
+	return 0, errors.New("unterminated double quoted value")
+}
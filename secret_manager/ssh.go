@@ -0,0 +1,143 @@
+package secret_manager
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/hashicorp/vault/api"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/data"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+const generatedSshKeyName = "id_ecdsa"
+
+func getSecretFromSsh(apiClient *api.Client, definition config.SecretDefinition, savedData *data.SavedData) (map[string]string, error) {
+	secretData, response, validBefore, err := signSshKey(apiClient, definition)
+
+	if nil != err {
+		return nil, fmt.Errorf("failed to sign SSH key with %s for secret %s: %w", definition.Source, definition.Name, err)
+	}
+
+	response.Data = nil
+
+	savedData.SetSecret(definition.Name, *response)
+	savedData.GetSavedSecret(definition.Name).ExpirationTimestamp = validBefore
+
+	return secretData, nil
+}
+
+// The secret contains the files in the OpenSSH naming convention: the private key (only if it was generated), the
+// public key with the .pub suffix and the certificate with the -cert.pub suffix
+func signSshKey(apiClient *api.Client, definition config.SecretDefinition) (map[string]string, *api.Secret, int, error) {
+	secretData, keyName, err := getSshKeys(definition.Ssh)
+
+	if nil != err {
+		return nil, nil, 0, err
+	}
+
+	body := getRequestParameters(definition)
+	body["public_key"] = secretData[keyName+".pub"]
+
+	if len(definition.Ssh.Principals) > 0 {
+		body["valid_principals"] = strings.Join(definition.Ssh.Principals, ",")
+	}
+
+	if "" != definition.Ssh.Ttl {
+		body["ttl"] = definition.Ssh.Ttl
+	}
+
+	if "" != definition.Ssh.CertType {
+		body["cert_type"] = definition.Ssh.CertType
+	}
+
+	glog.V(1).Info("Signing SSH key with " + definition.Source)
+	response, err := apiClient.Logical().Write(definition.Source, body)
+
+	if nil != err {
+		return nil, nil, 0, err
+	}
+
+	if nil == response || nil == response.Data {
+		return nil, nil, 0, errors.New("empty response from " + definition.Source)
+	}
+
+	signedKey := strings.TrimSpace(fmt.Sprintf("%v", response.Data["signed_key"]))
+	validBefore, err := getSshCertificateValidBefore(signedKey)
+
+	if nil != err {
+		return nil, nil, 0, err
+	}
+
+	secretData[keyName+"-cert.pub"] = signedKey + "\n"
+
+	glog.Infof("Signed SSH key with serial number %v", response.Data["serial_number"])
+
+	return secretData, response, validBefore, nil
+}
+
+func getSshKeys(definition *config.SshDefinition) (map[string]string, string, error) {
+	if "" != definition.PublicKeyFile {
+		publicKey, err := ioutil.ReadFile(definition.PublicKeyFile)
+
+		if nil != err {
+			return nil, "", err
+		}
+
+		keyName := strings.TrimSuffix(path.Base(definition.PublicKeyFile), ".pub")
+
+		return map[string]string{keyName + ".pub": string(publicKey)}, keyName, nil
+	}
+
+	glog.V(1).Info("Generating SSH key pair")
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if nil != err {
+		return nil, "", err
+	}
+
+	privateKeyBytes, err := x509.MarshalECPrivateKey(privateKey)
+
+	if nil != err {
+		return nil, "", err
+	}
+
+	publicKey, err := ssh.NewPublicKey(&privateKey.PublicKey)
+
+	if nil != err {
+		return nil, "", err
+	}
+
+	return map[string]string{
+		generatedSshKeyName:          string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privateKeyBytes})),
+		generatedSshKeyName + ".pub": string(ssh.MarshalAuthorizedKey(publicKey)),
+	}, generatedSshKeyName, nil
+}
+
+func getSshCertificateValidBefore(signedKey string) (int, error) {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signedKey))
+
+	if nil != err {
+		return 0, err
+	}
+
+	certificate, ok := publicKey.(*ssh.Certificate)
+
+	if !ok {
+		return 0, errors.New("the signed key is not an SSH certificate")
+	}
+
+	if certificate.ValidBefore == ssh.CertTimeInfinity {
+		return 0, nil
+	}
+
+	return int(certificate.ValidBefore), nil
+}
@@ -0,0 +1,101 @@
+package secret_manager
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/hashicorp/vault/api"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"sort"
+)
+
+func getSecretFromTransit(apiClient *api.Client, definition config.SecretDefinition) map[string]string {
+	ciphertexts := getCiphertexts(definition)
+	secretData, err := decryptCiphertexts(apiClient, definition.Source, ciphertexts)
+
+	if nil != err {
+		glog.Exit("Failed to decrypt secret "+definition.Name+" with "+definition.Source+": ", err)
+	}
+
+	return secretData
+}
+
+// The ciphertexts in the file are loaded first, so the inline ciphertexts override them
+func getCiphertexts(definition config.SecretDefinition) map[string]string {
+	ciphertexts := map[string]string{}
+
+	if "" != definition.Transit.CiphertextFile {
+		fileData, err := ioutil.ReadFile(definition.Transit.CiphertextFile)
+
+		if err != nil {
+			glog.Exit("Failed to read ciphertext file "+definition.Transit.CiphertextFile+": ", err)
+		}
+
+		err = yaml.Unmarshal(fileData, &ciphertexts)
+
+		if err != nil {
+			glog.Exit("Failed to parse ciphertext file "+definition.Transit.CiphertextFile+": ", err)
+		}
+	}
+
+	for key, ciphertext := range definition.Transit.Ciphertexts {
+		ciphertexts[key] = ciphertext
+	}
+
+	return ciphertexts
+}
+
+func decryptCiphertexts(apiClient *api.Client, decryptPath string, ciphertexts map[string]string) (map[string]string, error) {
+	keys := make([]string, 0, len(ciphertexts))
+
+	for key := range ciphertexts {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	batchInput := make([]map[string]interface{}, len(keys))
+
+	for i, key := range keys {
+		batchInput[i] = map[string]interface{}{"ciphertext": ciphertexts[key]}
+	}
+
+	glog.V(1).Infof("Decrypting %d values with %s", len(keys), decryptPath)
+	response, err := apiClient.Logical().Write(decryptPath, map[string]interface{}{"batch_input": batchInput})
+
+	if nil != err {
+		return nil, err
+	}
+
+	if nil == response || nil == response.Data {
+		return nil, errors.New("empty response from " + decryptPath)
+	}
+
+	batchResults, ok := response.Data["batch_results"].([]interface{})
+
+	if !ok || len(batchResults) != len(keys) {
+		return nil, errors.New("invalid batch results in the response from " + decryptPath)
+	}
+
+	secretData := map[string]string{}
+
+	for i, key := range keys {
+		result, _ := batchResults[i].(map[string]interface{})
+
+		if resultError, ok := result["error"]; ok && "" != fmt.Sprintf("%v", resultError) {
+			return nil, fmt.Errorf("failed to decrypt %s: %v", key, resultError)
+		}
+
+		plaintext, err := base64.StdEncoding.DecodeString(fmt.Sprintf("%v", result["plaintext"]))
+
+		if nil != err {
+			return nil, fmt.Errorf("invalid plaintext for %s: %v", key, err)
+		}
+
+		secretData[key] = string(plaintext)
+	}
+
+	return secretData, nil
+}
@@ -0,0 +1,53 @@
+package vault
+
+import (
+	"github.com/golang/glog"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+)
+
+type appRoleAuthenticator struct {
+	roleIdPath   string
+	secretIdPath string
+}
+
+func newAppRoleAuthenticator(appConfig config.Config) *appRoleAuthenticator {
+	return &appRoleAuthenticator{
+		roleIdPath:   appConfig.RoleIdPath,
+		secretIdPath: appConfig.SecretIdPath,
+	}
+}
+
+func (a *appRoleAuthenticator) Method() string {
+	return constants.AuthMethodAppRole
+}
+
+func (a *appRoleAuthenticator) LoginBody() (map[string]interface{}, error) {
+	roleId, err := readCredentialFile(a.roleIdPath)
+
+	if err != nil {
+		glog.Error("Failed to load the AppRole role ID: ", err)
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"role_id": roleId,
+	}
+
+	if "" == a.secretIdPath {
+		glog.V(1).Info("AppRole login without a secret ID")
+		return body, nil
+	}
+
+	secretId, err := readCredentialFile(a.secretIdPath)
+
+	if err != nil {
+		glog.Error("Failed to load the AppRole secret ID: ", err)
+		return nil, err
+	}
+
+	body["secret_id"] = secretId
+	glog.V(1).Infof("AppRole login with secret ID [%d bytes]", len(secretId))
+
+	return body, nil
+}
@@ -0,0 +1,37 @@
+package vault
+
+import (
+	"errors"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+	"io/ioutil"
+	"strings"
+)
+
+type Authenticator interface {
+	Method() string
+	LoginBody() (map[string]interface{}, error)
+}
+
+func NewAuthenticator(appConfig config.Config) (Authenticator, error) {
+	switch appConfig.AuthMethod {
+	case constants.AuthMethodKubernetes:
+		return newKubernetesAuthenticator(appConfig), nil
+	case constants.AuthMethodJwt:
+		return newJwtAuthenticator(appConfig), nil
+	case constants.AuthMethodAppRole:
+		return newAppRoleAuthenticator(appConfig), nil
+	default:
+		return nil, errors.New("Invalid auth method: " + appConfig.AuthMethod)
+	}
+}
+
+func readCredentialFile(filePath string) (string, error) {
+	contents, err := ioutil.ReadFile(filePath)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(contents)), nil
+}
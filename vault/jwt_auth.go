@@ -0,0 +1,95 @@
+package vault
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/golang/glog"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/helper"
+	"strings"
+)
+
+type jwtAuthenticator struct {
+	role      string
+	tokenPath string
+	audience  string
+}
+
+func newJwtAuthenticator(appConfig config.Config) *jwtAuthenticator {
+	return &jwtAuthenticator{
+		role:      appConfig.Role,
+		tokenPath: appConfig.TokenPath,
+		audience:  appConfig.JwtAudience,
+	}
+}
+
+func (a *jwtAuthenticator) Method() string {
+	return constants.AuthMethodJwt
+}
+
+func (a *jwtAuthenticator) LoginBody() (map[string]interface{}, error) {
+	// Projected tokens are rotated by the kubelet, so the token is read again on every login
+	token, err := readCredentialFile(a.tokenPath)
+
+	if err != nil {
+		glog.Error("Failed to load the JWT: ", err)
+		return nil, err
+	}
+
+	if "" != a.audience {
+		if err := validateJwtAudience(token, a.audience); err != nil {
+			glog.Error("Invalid JWT: ", err)
+			return nil, err
+		}
+	}
+
+	glog.V(1).Infof("JWT login using role %s jwt [%d bytes]", a.role, len(token))
+
+	return map[string]interface{}{
+		"role": a.role,
+		"jwt":  token,
+	}, nil
+}
+
+func validateJwtAudience(token string, audience string) error {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return errors.New("the token is not a valid JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+
+	if err != nil {
+		return errors.New("failed to decode the JWT payload: " + err.Error())
+	}
+
+	claims := struct {
+		Audience interface{} `json:"aud"`
+	}{}
+
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return errors.New("failed to parse the JWT payload: " + err.Error())
+	}
+
+	var tokenAudiences []string
+
+	switch v := claims.Audience.(type) {
+	case string:
+		tokenAudiences = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				tokenAudiences = append(tokenAudiences, s)
+			}
+		}
+	}
+
+	if !helper.StringInSlice(tokenAudiences, audience) {
+		return errors.New("the JWT is not issued for the audience " + audience)
+	}
+
+	return nil
+}
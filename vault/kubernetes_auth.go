@@ -0,0 +1,39 @@
+package vault
+
+import (
+	"github.com/golang/glog"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
+	"github.com/szeber/vault-kubernetes-dotenv-manager/constants"
+)
+
+type kubernetesAuthenticator struct {
+	role      string
+	tokenPath string
+}
+
+func newKubernetesAuthenticator(appConfig config.Config) *kubernetesAuthenticator {
+	return &kubernetesAuthenticator{
+		role:      appConfig.Role,
+		tokenPath: appConfig.TokenPath,
+	}
+}
+
+func (a *kubernetesAuthenticator) Method() string {
+	return constants.AuthMethodKubernetes
+}
+
+func (a *kubernetesAuthenticator) LoginBody() (map[string]interface{}, error) {
+	serviceAccountToken, err := readCredentialFile(a.tokenPath)
+
+	if err != nil {
+		glog.Error("Failed to load the service account token: ", err)
+		return nil, err
+	}
+
+	glog.V(1).Infof("Kubernetes login using role %s jwt [%d bytes]", a.role, len(serviceAccountToken))
+
+	return map[string]interface{}{
+		"role": a.role,
+		"jwt":  serviceAccountToken,
+	}, nil
+}
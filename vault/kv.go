@@ -0,0 +1,46 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/hashicorp/vault/api"
+	"strings"
+)
+
+type KvMount struct {
+	Path    string
+	Version int
+}
+
+func GetKvMount(apiClient *api.Client, secretPath string) (KvMount, error) {
+	glog.V(1).Info("Looking up the mount of " + secretPath)
+	response, err := apiClient.Logical().Read("sys/internal/ui/mounts/" + strings.TrimLeft(secretPath, "/"))
+
+	if err != nil {
+		return KvMount{}, err
+	}
+
+	if nil == response || nil == response.Data {
+		return KvMount{}, errors.New("no mount found for " + secretPath)
+	}
+
+	mountType := fmt.Sprintf("%v", response.Data["type"])
+
+	if "kv" != mountType && "generic" != mountType {
+		return KvMount{}, errors.New("the mount of " + secretPath + " is not a KV mount but " + mountType)
+	}
+
+	mount := KvMount{
+		Path:    fmt.Sprintf("%v", response.Data["path"]),
+		Version: 1,
+	}
+
+	if options, ok := response.Data["options"].(map[string]interface{}); ok && "2" == fmt.Sprintf("%v", options["version"]) {
+		mount.Version = 2
+	}
+
+	glog.V(1).Infof("Secret %s is in the KV version %d mount %s", secretPath, mount.Version, mount.Path)
+
+	return mount, nil
+}
@@ -1,8 +1,10 @@
 package vault
 
 import (
+	"errors"
 	"github.com/golang/glog"
 	"github.com/hashicorp/vault/api"
+	"net/http"
 )
 
 func RenewLease(apiClient *api.Client, secret api.Secret) (*api.Secret, error) {
@@ -35,6 +37,23 @@ func RenewLease(apiClient *api.Client, secret api.Secret) (*api.Secret, error) {
 	return createdSecret, nil
 }
 
+func RevokeLease(apiClient *api.Client, leaseID string) error {
+	glog.V(1).Info("Revoking lease " + leaseID)
+	request := apiClient.NewRequest("PUT", "/v1/sys/leases/revoke")
+
+	if err := request.SetJSONBody(map[string]interface{}{"lease_id": leaseID}); err != nil {
+		return err
+	}
+
+	response, err := apiClient.RawRequest(request)
+
+	if nil != response {
+		response.Body.Close()
+	}
+
+	return err
+}
+
 func RevokeTokenLease(apiClient *api.Client) {
 	glog.V(1).Info("Revoking token lease")
 	request := apiClient.NewRequest("POST", "/v1/auth/token/revoke-self")
@@ -66,3 +85,19 @@ func RenewTokenLease(apiClient *api.Client) (int, error) {
 
 	return secret.Auth.LeaseDuration, nil
 }
+
+// IsTokenRevoked returns whether Vault rejects the token of the client. Any other error, like a network error, leaves
+// the state of the token unknown, so the token is not considered revoked then.
+func IsTokenRevoked(apiClient *api.Client) bool {
+	glog.V(1).Info("Looking up the token")
+	request := apiClient.NewRequest("GET", "/v1/auth/token/lookup-self")
+	response, err := apiClient.RawRequest(request)
+
+	if nil != response {
+		response.Body.Close()
+	}
+
+	var responseError *api.ResponseError
+
+	return errors.As(err, &responseError) && http.StatusForbidden == responseError.StatusCode
+}
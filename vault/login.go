@@ -1,33 +1,42 @@
 package vault
 
 import (
+	"fmt"
 	"github.com/golang/glog"
 	"github.com/hashicorp/vault/api"
 	"github.com/szeber/vault-kubernetes-dotenv-manager/config"
-	"io/ioutil"
 	"net/http"
 	"path"
 	"strings"
 )
 
 type AuthConfig struct {
-	Url                 string
-	Namespace           string
-	KubeAuthRole        string
-	KubeAuthPath        string
-	ServiceAccountToken string
+	Url           string
+	Namespace     string
+	AuthPath      string
+	Authenticator Authenticator
 }
 
 func LoginWithAppConfig(appConfig config.Config) (*api.Client, int) {
-	apiClient, leaseDuration, err := Login(getAuthConfigFromAppConfig(appConfig))
+	apiClient, leaseDuration, err := TryLoginWithAppConfig(appConfig)
 
 	if nil != err {
-		glog.Exit("Failed to log in to Vault")
+		glog.Exit("Failed to log in to Vault: ", err)
 	}
 
 	return apiClient, leaseDuration
 }
 
+func TryLoginWithAppConfig(appConfig config.Config) (*api.Client, int, error) {
+	authConfig, err := getAuthConfigFromAppConfig(appConfig)
+
+	if nil != err {
+		return nil, 0, err
+	}
+
+	return Login(authConfig)
+}
+
 func Login(authConfig AuthConfig) (*api.Client, int, error) {
 	apiClient, err := getApiClient(authConfig)
 
@@ -50,22 +59,19 @@ func Login(authConfig AuthConfig) (*api.Client, int, error) {
 }
 
 func sendLoginRequest(apiClient *api.Client, authConfig AuthConfig) (*api.Secret, error) {
-	body := map[string]interface{}{
-		"role": authConfig.KubeAuthRole,
-		"jwt":  authConfig.ServiceAccountToken,
-	}
-
-	loginPath := "/v1/auth/" + authConfig.KubeAuthPath + "/login"
+	loginPath := "/v1/auth/" + authConfig.AuthPath + "/login"
 	loginPath = path.Clean(loginPath)
-	glog.V(1).Infof(
-		"Vault login using path %s role %s jwt [%d bytes]",
-		loginPath,
-		authConfig.KubeAuthRole,
-		len(authConfig.ServiceAccountToken),
-	)
+	glog.V(1).Infof("Vault login using path %s with auth method %s", loginPath, authConfig.Authenticator.Method())
+
+	body, err := authConfig.Authenticator.LoginBody()
+
+	if err != nil {
+		glog.Error("ERROR: Failed to build the login request body: ", err)
+		return nil, err
+	}
 
 	req := apiClient.NewRequest("POST", loginPath)
-	err := req.SetJSONBody(body)
+	err = req.SetJSONBody(body)
 
 	if err != nil {
 		glog.Error("ERROR: Failed to set json body: ", err)
@@ -125,24 +131,27 @@ func buildHTTPClient(url string) *http.Client {
 	return httpClient
 }
 
-func getAuthConfigFromAppConfig(appConfig config.Config) AuthConfig {
-	authToken, err := ioutil.ReadFile(appConfig.TokenPath)
+func getAuthConfigFromAppConfig(appConfig config.Config) (AuthConfig, error) {
+	authenticator, err := NewAuthenticator(appConfig)
 
 	if err != nil {
-		glog.Exit("Failed to load the service account token: ", err)
+		return AuthConfig{}, fmt.Errorf("failed to set up the %s authenticator: %w", appConfig.AuthMethod, err)
 	}
 
 	return AuthConfig{
-		Url:                 appConfig.VaultUrl,
-		Namespace:           appConfig.Namespace,
-		KubeAuthRole:        appConfig.Role,
-		KubeAuthPath:        appConfig.VaultAuthMethodPath,
-		ServiceAccountToken: string(authToken),
-	}
+		Url:           appConfig.VaultUrl,
+		Namespace:     appConfig.Namespace,
+		AuthPath:      appConfig.VaultAuthMethodPath,
+		Authenticator: authenticator,
+	}, nil
 }
 
 func GetClientWithToken(appConfig config.Config, token string) (*api.Client, error) {
-	authConfig := getAuthConfigFromAppConfig(appConfig)
+	authConfig, err := getAuthConfigFromAppConfig(appConfig)
+
+	if err != nil {
+		return nil, err
+	}
 
 	apiClient, err := getApiClient(authConfig)
 